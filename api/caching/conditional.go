@@ -0,0 +1,108 @@
+package caching
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/gin-gonic/gin"
+)
+
+// Conditional will add a strong ETag, computed from the body, and a Cache-Control header to the successful responses
+// of the handler. The requests with a matching If-None-Match, or an If-Modified-Since that is not older than the
+// Last-Modified header set by the handler, are answered with a 304 and no body.
+// A max age of 0 means that the clients must revalidate the response every time they use it
+func Conditional(maxAge time.Duration, handler gin.HandlerFunc) gin.HandlerFunc {
+	cacheControl := "no-cache"
+	if maxAge > 0 {
+		cacheControl = fmt.Sprintf("max-age=%d", int(maxAge.Seconds()))
+	}
+
+	return func(c *gin.Context) {
+		writer := responses.NewBufferedWriter(c.Writer)
+		c.Writer = writer
+
+		handler(c)
+
+		c.Writer = writer.ResponseWriter
+		flush(writer, c.Request, cacheControl)
+	}
+}
+
+// SetLastModified will set the Last-Modified header of the response, it is used to answer If-Modified-Since
+func SetLastModified(c *gin.Context, lastModified time.Time) {
+	if lastModified.IsZero() {
+		return
+	}
+
+	c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+}
+
+// flush will send the buffered response, or a 304 when the client already has it
+func flush(w *responses.BufferedWriter, r *http.Request, cacheControl string) {
+	header := w.ResponseWriter.Header()
+
+	if w.ResponseWriter.Status() != http.StatusOK || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		w.Send()
+		return
+	}
+
+	sum := sha256.Sum256(w.Body())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", cacheControl)
+	// the body depends on the format asked by the client
+	header.Add("Vary", "Accept")
+
+	if notModified(r, etag, header.Get("Last-Modified")) {
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+
+	w.Send()
+}
+
+// notModified will tell if the client already has the current version of the response, see RFC 7232.
+// If-Modified-Since is ignored when If-None-Match is present
+func notModified(r *http.Request, etag string, lastModified string) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, etag)
+	}
+
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || lastModified == "" {
+		return false
+	}
+
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+
+	return !modified.After(since)
+}
+
+// etagMatches uses the weak comparison, as required for If-None-Match
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
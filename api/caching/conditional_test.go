@@ -0,0 +1,98 @@
+package caching
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+var modified = time.Date(2019, 10, 1, 12, 30, 0, 0, time.UTC)
+
+func newRouter(maxAge time.Duration) *gin.Engine {
+	r := gin.New()
+	r.GET("/pet/:id", Conditional(maxAge, func(c *gin.Context) {
+		if c.Param("id") == "0" {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Pet not found"})
+			return
+		}
+
+		SetLastModified(c, modified)
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	}))
+
+	return r
+}
+
+func get(r *gin.Engine, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func TestConditional(t *testing.T) {
+	r := newRouter(time.Minute)
+
+	recorder := get(r, "/pet/1", nil)
+	etag := recorder.Header().Get("ETag")
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, `{"id":"1"}`, recorder.Body.String())
+	require.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+	require.Equal(t, "max-age=60", recorder.Header().Get("Cache-Control"))
+	require.Equal(t, "Tue, 01 Oct 2019 12:30:00 GMT", recorder.Header().Get("Last-Modified"))
+
+	// the same body always has the same ETag, another body has another one
+	require.Equal(t, etag, get(r, "/pet/1", nil).Header().Get("ETag"))
+	require.NotEqual(t, etag, get(r, "/pet/2", nil).Header().Get("ETag"))
+
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		recorder = get(r, "/pet/1", map[string]string{"If-None-Match": ifNoneMatch})
+
+		require.Equal(t, http.StatusNotModified, recorder.Code, ifNoneMatch)
+		require.Empty(t, recorder.Body.String())
+		require.Equal(t, etag, recorder.Header().Get("ETag"))
+	}
+
+	recorder = get(r, "/pet/1", map[string]string{"If-None-Match": `"other"`})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, `{"id":"1"}`, recorder.Body.String())
+}
+
+func TestConditional_ifModifiedSince(t *testing.T) {
+	r := newRouter(0)
+
+	recorder := get(r, "/pet/1", map[string]string{"If-Modified-Since": "Tue, 01 Oct 2019 12:30:00 GMT"})
+	require.Equal(t, http.StatusNotModified, recorder.Code)
+	require.Equal(t, "no-cache", recorder.Header().Get("Cache-Control"))
+
+	recorder = get(r, "/pet/1", map[string]string{"If-Modified-Since": "Tue, 01 Oct 2019 12:29:59 GMT"})
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// If-None-Match takes precedence over If-Modified-Since
+	recorder = get(r, "/pet/1", map[string]string{
+		"If-None-Match":     `"other"`,
+		"If-Modified-Since": "Tue, 01 Oct 2019 12:30:00 GMT",
+	})
+	require.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestConditional_errors(t *testing.T) {
+	r := newRouter(time.Minute)
+
+	recorder := get(r, "/pet/0", map[string]string{"If-None-Match": "*"})
+
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+	require.Equal(t, `{"message":"Pet not found"}`, recorder.Body.String())
+	require.Empty(t, recorder.Header().Get("ETag"))
+	require.Empty(t, recorder.Header().Get("Cache-Control"))
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/gin-gonic/gin"
+)
+
+// requireAPIKey will make sure that the api_key header is supplied.
+// A 401 response is sent when it is not and the handler should stop
+func requireAPIKey(c *gin.Context) bool {
+	if len(c.GetHeader("api_key")) == 0 {
+		log.Print("API key not supplied")
+		responses.Error(c, http.StatusUnauthorized, "unauthorized - API key not supplied in Headers")
+		return false
+	}
+
+	return true
+}
+
+// apiKeyOwner will return the owner of the resources saved with the api_key header: a hash of the key, the key itself is never stored.
+// A 401 response is sent when the key is not supplied and the handler should stop
+func apiKeyOwner(c *gin.Context) (string, bool) {
+	if !requireAPIKey(c) {
+		return "", false
+	}
+
+	sum := sha256.Sum256([]byte(c.GetHeader("api_key")))
+	return hex.EncodeToString(sum[:]), true
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/gin-gonic/gin"
+)
+
+// InvalidIDMessage is the message of the 400 sent for the ids that are not positive integers
+const InvalidIDMessage = "Invalid ID supplied"
+
+// idParam will read the id param of the url, it must be a positive integer.
+// A 400 response is sent when it is not and the handler should stop
+func idParam(c *gin.Context) (string, bool) {
+	return positiveIntParam(c, "id")
+}
+
+// positiveIntParam will read a param of the url that must be a positive integer, like idParam
+func positiveIntParam(c *gin.Context, name string) (string, bool) {
+	id := c.Param(name)
+
+	parsed, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || parsed == 0 {
+		responses.Error(c, http.StatusBadRequest, InvalidIDMessage)
+		return "", false
+	}
+
+	return id, true
+}
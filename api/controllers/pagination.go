@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/YannHulot/petstore/api/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultPageLimit is the number of pets returned when the client does not send a limit
+	defaultPageLimit = 100
+	// maxPageLimit is the maximum number of pets that can be returned in a single page
+	maxPageLimit = 1000
+)
+
+// parsePage will read the limit, cursor and count query params
+func parsePage(c *gin.Context) (services.Page, error) {
+	result := services.Page{Limit: defaultPageLimit}
+
+	if limit, ok := c.GetQuery("limit"); ok {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > maxPageLimit {
+			return services.Page{}, fmt.Errorf("Invalid limit value")
+		}
+		result.Limit = parsed
+	}
+
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		parsed, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return services.Page{}, fmt.Errorf("Invalid cursor value")
+		}
+		result.Cursor = parsed
+	}
+
+	if count, ok := c.GetQuery("count"); ok {
+		parsed, err := strconv.ParseBool(count)
+		if err != nil {
+			return services.Page{}, fmt.Errorf("Invalid count value")
+		}
+		result.Count = parsed
+	}
+
+	return result, nil
+}
+
+// setPageHeaders will add the link to the next page when there is one, and the number of pets when it was counted
+func setPageHeaders(c *gin.Context, result *services.PetPage) {
+	if result.HasNext {
+		setNextPageLink(c, result.Pets[len(result.Pets)-1].ID)
+	}
+
+	if result.Total != nil {
+		setTotalCount(c, *result.Total)
+	}
+}
+
+// setNextPageLink will add a Link header pointing to the page that starts after the given id
+func setNextPageLink(c *gin.Context, lastID uint64) {
+	next := *c.Request.URL
+	query := next.Query()
+	query.Set("cursor", strconv.FormatUint(lastID, 10))
+	next.RawQuery = query.Encode()
+
+	c.Header("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+}
+
+// setTotalCount will add the X-Total-Count header
+func setTotalCount(c *gin.Context, total int) {
+	c.Header("X-Total-Count", strconv.Itoa(total))
+}
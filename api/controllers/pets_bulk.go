@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/gin-gonic/gin"
+)
+
+// The modes of a bulk request, given by the mode query param
+const (
+	// atomicMode saves every item or none of them, it is the default
+	atomicMode = "atomic"
+	// bestEffortMode saves the valid items and reports the others
+	bestEffortMode = "best-effort"
+)
+
+// SavePets will save the pets of a JSON array, e.g POST /pet/bulk?mode=best-effort.
+// The response has a result per pet, an atomic request with an invalid pet is answered with a 400 and nothing is saved
+func (p *PetController) SavePets(c *gin.Context) {
+	atomic, valid := bulkMode(c)
+	if !valid {
+		return
+	}
+
+	var pets []models.Pet
+	err := c.ShouldBindJSON(&pets)
+	if err != nil {
+		log.Printf("failed parsing the body of the request: %v", err)
+		invalidInput(c, err)
+		return
+	}
+
+	results, err := p.Service.CreatePets(pets, atomic)
+	if err != nil {
+		petError(c, err, "save the pets in the db")
+		return
+	}
+
+	bulkResponse(c, atomic, results)
+}
+
+// UpdatePets will replace the pets of a JSON array, the pets are found by the ids of the payloads
+func (p *PetController) UpdatePets(c *gin.Context) {
+	atomic, valid := bulkMode(c)
+	if !valid {
+		return
+	}
+
+	var pets []models.Pet
+	err := c.ShouldBindJSON(&pets)
+	if err != nil {
+		log.Printf("failed parsing the body of the request: %v", err)
+		invalidInput(c, err)
+		return
+	}
+
+	results, err := p.Service.ReplacePets(pets, atomic)
+	if err != nil {
+		petError(c, err, "update the pets in the db")
+		return
+	}
+
+	bulkResponse(c, atomic, results)
+}
+
+// DeletePets will delete the pets whose ids are in a JSON array, e.g [1, 2, 3]
+func (p *PetController) DeletePets(c *gin.Context) {
+	if !requireAPIKey(c) {
+		return
+	}
+
+	atomic, valid := bulkMode(c)
+	if !valid {
+		return
+	}
+
+	var ids []uint64
+	err := c.ShouldBindJSON(&ids)
+	if err != nil {
+		log.Printf("failed parsing the body of the request: %v", err)
+		invalidInput(c, err)
+		return
+	}
+
+	results, err := p.Service.DeletePets(ids, atomic)
+	if err != nil {
+		petError(c, err, "delete the pets in the db")
+		return
+	}
+
+	bulkResponse(c, atomic, results)
+}
+
+// bulkMode will read the mode query param, a 400 response is sent when it is not a known mode
+func bulkMode(c *gin.Context) (atomic bool, valid bool) {
+	switch c.Query("mode") {
+	case "", atomicMode:
+		return true, true
+	case bestEffortMode:
+		return false, true
+	}
+
+	responses.ValidationError(c, models.ValidationErrors{{
+		Field:   "mode",
+		Code:    models.CodeInvalidValue,
+		Message: "must be one of " + atomicMode + " or " + bestEffortMode,
+	}})
+	return false, false
+}
+
+// bulkResponse will send the results, a 400 when an atomic request failed and a 200 otherwise
+func bulkResponse(c *gin.Context, atomic bool, results []models.BulkResult) {
+	response := models.NewBulkResponse(atomic, results)
+
+	code := http.StatusOK
+	if atomic && response.Failed > 0 {
+		code = http.StatusBadRequest
+	}
+
+	c.JSON(code, response)
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/go-test/deep"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *Suite) bulkRequest(method string, path string, body string) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.POST("/api/v1/pet/bulk", s.controller.SavePets)
+	r.PUT("/api/v1/pet/bulk", s.controller.UpdatePets)
+	r.DELETE("/api/v1/pet/bulk", s.controller.DeletePets)
+
+	req, err := http.NewRequest(method, path, strings.NewReader(body))
+	require.NoError(s.T(), err)
+	req.Header.Add("api_key", "test-key")
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func (s *Suite) Test_SavePets_atomic() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO "pets" ("name","photos_urls","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5),($6,$7,$8,$9,$10) RETURNING "id"`)).
+		WithArgs("rex", nil, "available", sqlmock.AnyArg(), sqlmock.AnyArg(), "tom", nil, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4).AddRow(5))
+	s.mock.ExpectCommit()
+
+	recorder := s.bulkRequest("POST", "/api/v1/pet/bulk", `[{"name":"rex","status":"available"},{"name":"tom"}]`)
+
+	expectedResponse := `{"atomic":true,"succeeded":2,"failed":0,"results":[` +
+		`{"index":0,"id":4,"status":"created"},{"index":1,"id":5,"status":"created"}]}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+
+	// nothing is saved when a pet is invalid
+	recorder = s.bulkRequest("POST", "/api/v1/pet/bulk", `[{"name":"rex"},{"status":"lost"}]`)
+
+	expectedResponse = `{"atomic":true,"succeeded":0,"failed":2,"results":[` +
+		`{"index":0,"status":"skipped","message":"Not saved because another item failed"},` +
+		`{"index":1,"status":"error","message":"Invalid input","errors":[` +
+		`{"field":"name","code":"required","message":"cannot be empty"},` +
+		`{"field":"status","code":"invalid_value","message":"must be one of available, pending or sold"}]}]}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+}
+
+func (s *Suite) Test_SavePets_bestEffort() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO "pets" ("name","photos_urls","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5) RETURNING "id"`)).
+		WithArgs("tom", nil, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+	s.mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "tags" ("name","pet_id") VALUES ($1,$2) RETURNING "id"`)).
+		WithArgs("small", 6).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	s.mock.ExpectCommit()
+
+	recorder := s.bulkRequest("POST", "/api/v1/pet/bulk?mode=best-effort", `[{"name":""},{"name":"tom","tags":[{"name":"small"}]}]`)
+
+	expectedResponse := `{"atomic":false,"succeeded":1,"failed":1,"results":[` +
+		`{"index":0,"status":"error","message":"Invalid input","errors":[{"field":"name","code":"required","message":"cannot be empty"}]},` +
+		`{"index":1,"id":6,"status":"created"}]}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+}
+
+func (s *Suite) Test_SavePets_invalidRequest() {
+	for query, expected := range map[string]string{
+		"?mode=all": `{"field":"mode","code":"invalid_value","message":"must be one of atomic or best-effort"}`,
+		"":          `{"field":"body","code":"required","message":"cannot be empty"}`,
+	} {
+		recorder := s.bulkRequest("POST", "/api/v1/pet/bulk"+query, `[]`)
+
+		require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+		require.Nil(s.T(), deep.Equal(recorder.Body.String(), `{"code":400,"type":"error","message":"Invalid input","errors":[`+expected+`]}`))
+	}
+
+	recorder := s.bulkRequest("POST", "/api/v1/pet/bulk", `{"name":"rex"}`)
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+}
+
+func (s *Suite) Test_UpdatePets_missingID() {
+	recorder := s.bulkRequest("PUT", "/api/v1/pet/bulk?mode=best-effort", `[{"name":"rex"}]`)
+
+	expectedResponse := `{"atomic":false,"succeeded":0,"failed":1,"results":[` +
+		`{"index":0,"status":"error","message":"Invalid input","errors":[{"field":"id","code":"required","message":"cannot be empty"}]}]}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+}
+
+func (s *Suite) Test_DeletePets() {
+	// best effort, every pet has its own transaction, the ones that fail are rolled back
+	s.expectPetDeleted("2", 1)
+	s.expectPetDeleted("3", 0)
+
+	recorder := s.bulkRequest("DELETE", "/api/v1/pet/bulk?mode=best-effort", `[2,3]`)
+
+	expectedResponse := `{"atomic":false,"succeeded":1,"failed":1,"results":[` +
+		`{"index":0,"id":2,"status":"deleted"},{"index":1,"id":3,"status":"error","message":"Pet not found"}]}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+
+	// atomic, the pets share a transaction that is rolled back
+	s.mock.ExpectBegin()
+	for _, pet := range []struct {
+		id   string
+		rows int64
+	}{{"2", 1}, {"3", 0}} {
+		s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "pet_images" WHERE (pet_id = $1)`)).
+			WithArgs(pet.id).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		for _, table := range []string{"pet_images", "tags", "categories"} {
+			s.mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "` + table + `" WHERE (pet_id = $1)`)).
+				WithArgs(pet.id).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		s.mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "pets" WHERE (id = $1)`)).
+			WithArgs(pet.id).
+			WillReturnResult(sqlmock.NewResult(0, pet.rows))
+	}
+	s.mock.ExpectRollback()
+
+	recorder = s.bulkRequest("DELETE", "/api/v1/pet/bulk", `[2,3]`)
+
+	expectedResponse = `{"atomic":true,"succeeded":0,"failed":2,"results":[` +
+		`{"index":0,"id":2,"status":"skipped","message":"Not saved because another item failed"},` +
+		`{"index":1,"id":3,"status":"error","message":"Pet not found"}]}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+}
@@ -5,216 +5,308 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/YannHulot/petstore/api/caching"
 	"github.com/YannHulot/petstore/api/models"
-	"github.com/YannHulot/petstore/api/repository"
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/YannHulot/petstore/api/services"
 	"github.com/gin-gonic/gin"
-	"github.com/jinzhu/gorm"
 )
 
-// PetController is a wrapper for all the handlers
+// PetController is a wrapper for all the handlers of the v1 API, it follows the petstore spec
 type PetController struct {
-	Repository repository.PetRepository
+	Service *services.PetService
+	Images  *services.ImageService
 }
 
 // NewPetController will create a new PetController
-func NewPetController(repository repository.PetRepository) PetController {
+func NewPetController(service *services.PetService, images *services.ImageService) PetController {
 	return PetController{
-		Repository: repository,
+		Service: service,
+		Images:  images,
 	}
 }
 
+// maxFormOverhead is the room left in the bodies of the uploads for the other fields and the headers of the parts
+const maxFormOverhead = 64 << 10
+
+// MaxUploadBodySize is the size in bytes above which the bodies of the uploads are answered with a 413
+func (p *PetController) MaxUploadBodySize() int64 {
+	return p.Images.MaxSize() + maxFormOverhead
+}
+
+// petError will reply with the error returned by the service
+func petError(c *gin.Context, err error, action string) {
+	if errs, ok := err.(models.ValidationErrors); ok {
+		invalidInput(c, errs)
+		return
+	}
+
+	if err == services.ErrPetNotFound {
+		responses.Error(c, http.StatusNotFound, "Pet not found")
+		return
+	}
+
+	if err == services.ErrImageNotFound {
+		responses.Error(c, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	if err == services.ErrUnsupportedImage {
+		responses.Error(c, http.StatusUnsupportedMediaType, "The file must be a PNG, JPEG, WebP or GIF image")
+		return
+	}
+
+	log.Printf("failed to %s: %v", action, err)
+	responses.InternalError(c)
+}
+
 // UpdatePetWithFormData will update a pet's name and status in the database
 func (p *PetController) UpdatePetWithFormData(c *gin.Context) {
 	// get the param id(e.g 1) from the url
-	id := c.Param("id")
-	if id == "" {
-		c.JSON(405, gin.H{"type": "error", "message": "Invalid input"})
+	id, valid := idParam(c)
+	if !valid {
 		return
 	}
 
 	// get the form data from the request
-	name := c.PostForm("name")
-	status := c.PostForm("status")
-	if len(name) == 0 && len(status) == 0 {
-		c.JSON(405, gin.H{"type": "error", "message": "Invalid input"})
-		return
-	}
-
-	updatedPet, err := p.Repository.UpdatePetAttributes(id, name, status)
+	name := strings.TrimSpace(c.PostForm("name"))
+	status := strings.TrimSpace(c.PostForm("status"))
+	updatedPet, err := p.Service.UpdatePetAttributes(id, name, status)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"type": "error", "message": "Invalid input"})
+		petError(c, err, "update the pet in the db")
 		return
 	}
 
-	c.JSON(http.StatusOK, updatedPet)
+	responses.Render(c, http.StatusOK, updatedPet)
 }
 
-// UploadFile will save a file in local storage
+// UploadFile will keep an image of the pet in the storage and add its URL to the photo URLs of the pet.
+// Only the PNG, JPEG, WebP and GIF images within the limits of size and dimensions are kept
 func (p *PetController) UploadFile(c *gin.Context) {
-	id := c.Param("id")
 	var form models.FileForm
 
-	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"type": "error", "message": "Invalid Id param"})
+	id, valid := idParam(c)
+	if !valid {
+		return
+	}
+
+	// the body is limited here too, the handler can be mounted without the validator
+	if !responses.LimitBody(c, p.MaxUploadBodySize()) {
 		return
 	}
 
 	if err := c.ShouldBind(&form); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"type": "error", "message": "Invalid form value"})
+		if limit, exceeded := responses.BodyLimitExceeded(c.Request); exceeded {
+			responses.BodyTooLarge(c, limit)
+			return
+		}
+
+		responses.Error(c, http.StatusBadRequest, "Invalid form value")
 		return
 	}
 
-	err := c.SaveUploadedFile(form.File, form.File.Filename)
+	file, err := form.File.Open()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"type": "error", "message": err.Error()})
+		log.Printf("failed to open the uploaded file: %v", err)
+		responses.InternalError(c)
+		return
+	}
+	defer file.Close()
+
+	image, err := p.Images.UploadImage(id, services.Upload{
+		Filename: form.File.Filename,
+		Size:     form.File.Size,
+		Content:  file,
+	})
+	if err == services.ErrImageTooLarge {
+		responses.Error(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("The file cannot be larger than %d bytes", p.Images.MaxSize()))
+		return
+	}
+	if err != nil {
+		petError(c, err, "upload the image")
 		return
 	}
 
 	message := fmt.Sprintf(
-		"additionalMetadata: %s\nFile uploaded to ./%s, %v bytes",
-		form.AdditionalMetadata, form.File.Filename, form.File.Size,
+		"additionalMetadata: %s\nFile uploaded to %s, %v bytes",
+		form.AdditionalMetadata, image.URL, image.Size,
 	)
 
-	c.JSON(http.StatusOK, gin.H{"type": "unknown", "message": message})
+	responses.Render(c, http.StatusOK, responses.APIResponse{Code: http.StatusOK, Type: "unknown", Message: message})
 }
 
 // SavePet will save the pet in the database
 func (p *PetController) SavePet(c *gin.Context) {
 	var petToSave models.Pet
 
-	err := c.ShouldBindJSON(&petToSave)
+	err := responses.Bind(c, &petToSave)
 	if err != nil {
 		log.Printf("failed parsing the body of the request: %v", err)
-		c.JSON(405, gin.H{"type": "error", "message": "Invalid input"})
+		invalidInput(c, err)
 		return
 	}
 
-	// sanitise the data before saving
-	petToSave.Sanitise()
-
-	pet, err := p.Repository.SavePet(&petToSave)
+	pet, err := p.Service.CreatePet(&petToSave)
 	if err != nil {
-		log.Printf("failed saving the pet in the db: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		petError(c, err, "save the pet in the db")
 		return
 	}
 
-	c.JSON(http.StatusOK, pet)
+	responses.Render(c, http.StatusOK, pet)
 }
 
-// FindPetByIDOrStatus will find a pet by its ID or status
-func (p *PetController) FindPetByIDOrStatus(c *gin.Context) {
-	// the id param is coming from the wildcard match in the router
-	id := c.Param("id")
-	isFindByStatus := strings.Contains(id, "findByStatus")
-
-	// WARNING: this is a bit of a hack
-	// gin router has some issues with wildcard in its pattern matching algorithm
-	// so I had to be creative to follow the swagger template
-	// see: https://github.com/gin-gonic/gin/issues/1301
-	if isFindByStatus {
-		p.findPetByStatus(c)
+// FindPetByID will find a pet by its ID
+func (p *PetController) FindPetByID(c *gin.Context) {
+	id, valid := idParam(c)
+	if !valid {
 		return
 	}
 
-	p.findPetByID(c, id)
-}
-
-// findPetByID will find a pet by its ID
-func (p *PetController) findPetByID(c *gin.Context, id string) {
-	pet, err := p.Repository.FindPetByID(id)
+	pet, err := p.Service.GetPet(id)
 	if err != nil {
-		if gorm.IsRecordNotFoundError(err) {
-			log.Printf("failed to find the pet in the db: %v", err)
-			c.JSON(http.StatusNotFound, gin.H{"type": "error", "message": "invalid input"})
-			return
-		}
-		log.Printf("failed to find the pet in the db: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"type": "error", "message": err.Error()})
+		petError(c, err, "find the pet in the db")
 		return
 	}
 
-	c.JSON(http.StatusOK, pet)
+	caching.SetLastModified(c, lastModified(*pet))
+	responses.Render(c, http.StatusOK, pet)
 }
 
-// findPetByStatus will find a pet/pets in the db by its status or statuses
-func (p *PetController) findPetByStatus(c *gin.Context) {
-	var finalPets []models.Pet
-	authorizedStatuses := map[string]bool{"sold": true, "available": true, "pending": true}
-
+// FindPetByStatus will find a pet/pets in the db by its status or statuses.
+// The pets are sorted by id and paginated with the limit and cursor query params
+func (p *PetController) FindPetByStatus(c *gin.Context) {
 	statuses, valid := c.GetQueryArray("status")
 	if !valid {
 		log.Print("status is empty")
-		c.JSON(400, gin.H{"type": "error", "message": "Invalid status value"})
+		responses.Error(c, http.StatusBadRequest, "Invalid status value")
 		return
 	}
 
 	for _, status := range statuses {
-		ok := authorizedStatuses[status]
+		ok := models.PetStatuses[status]
 		if !ok {
 			log.Print("status is not authorized")
-			c.JSON(400, gin.H{"type": "error", "message": "Invalid status value"})
+			responses.Error(c, http.StatusBadRequest, "Invalid status value")
 			return
 		}
+	}
+
+	requestedPage, err := parsePage(c)
+	if err != nil {
+		log.Printf("invalid pagination: %v", err)
+		responses.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := p.Service.FindPetsByStatus(statuses, requestedPage)
+	if err != nil {
+		petError(c, err, "find the pets in the db")
+		return
+	}
+
+	// the lists have no Last-Modified: deleting a pet or changing its status leaves the dates of the other pets
+	// as they were, only the ETag of the body tells that the list has changed
+	setPageHeaders(c, result)
+	responses.Render(c, http.StatusOK, models.Pets(result.Pets))
+}
+
+// lastModified returns the last time the pet was changed, it is zero when the dates are unknown
+func lastModified(pet models.Pet) time.Time {
+	var last time.Time
+
+	for _, date := range []*time.Time{pet.CreatedAt, pet.UpdatedAt} {
+		if date != nil && date.After(last) {
+			last = *date
+		}
+	}
+
+	return last
+}
 
-		pets, err := p.Repository.FindPetByStatus(status)
-		if err != nil {
-			log.Printf("failed to find the pet in the db: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"type": "error", "message": err.Error()})
+// FindPetByTags will find the pets that have any of the tags, or all of them with match=all.
+// The pets are sorted by id and paginated with the limit and cursor query params
+func (p *PetController) FindPetByTags(c *gin.Context) {
+	tags, valid := c.GetQueryArray("tags")
+	if !valid {
+		log.Print("tags are empty")
+		responses.Error(c, http.StatusBadRequest, "Invalid tag value")
+		return
+	}
+
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			log.Print("tag is empty")
+			responses.Error(c, http.StatusBadRequest, "Invalid tag value")
 			return
 		}
+	}
 
-		finalPets = append(finalPets, *pets...)
+	matchAll := false
+	switch c.DefaultQuery("match", "any") {
+	case "any":
+	case "all":
+		matchAll = true
+	default:
+		log.Print("match mode is not supported")
+		responses.Error(c, http.StatusBadRequest, "Invalid match value")
+		return
+	}
+
+	requestedPage, err := parsePage(c)
+	if err != nil {
+		log.Printf("invalid pagination: %v", err)
+		responses.Error(c, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	c.JSON(http.StatusOK, finalPets)
+	result, err := p.Service.FindPetsByTags(tags, matchAll, requestedPage)
+	if err != nil {
+		petError(c, err, "find the pets in the db")
+		return
+	}
+
+	setPageHeaders(c, result)
+	responses.Render(c, http.StatusOK, models.Pets(result.Pets))
 }
 
 // DeletePet will delete a single Pet from the DB
 func (p *PetController) DeletePet(c *gin.Context) {
-	apiKey := c.GetHeader("api_key")
-	id := c.Param("id")
+	if !requireAPIKey(c) {
+		return
+	}
 
-	if len(apiKey) == 0 {
-		log.Print("API key not supplied")
-		err := fmt.Errorf("unauthorized - API key not supplied in Headers")
-		c.JSON(http.StatusUnauthorized, gin.H{"type": "error", "message": err.Error()})
+	id, valid := idParam(c)
+	if !valid {
 		return
 	}
 
-	err := p.Repository.DeletePet(id)
+	err := p.Service.DeletePet(id)
 	if err != nil {
-		if gorm.IsRecordNotFoundError(err) {
-			log.Printf("failed to find the pet in the db: %v", err)
-			c.JSON(404, gin.H{"type": "error", "message": "Pet not found"})
-			return
-		}
-		log.Printf("failed to delete the pet or associated records in the db: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"type": "error", "message": err.Error()})
+		petError(c, err, "delete the pet or associated records in the db")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{})
+	responses.Render(c, http.StatusOK, gin.H{})
 }
 
 // UpdatePet will update a single pet in the DB
 func (p *PetController) UpdatePet(c *gin.Context) {
 	var petToSave models.Pet
 
-	err := c.ShouldBindJSON(&petToSave)
+	err := responses.Bind(c, &petToSave)
 	if err != nil {
 		log.Printf("failed parsing the body of the request: %v", err)
-		c.JSON(405, gin.H{"type": "Invalid input", "message": err.Error()})
+		invalidInput(c, err)
 		return
 	}
 
-	pet, err := p.Repository.UpdatePet(&petToSave)
+	pet, err := p.Service.UpdatePet(&petToSave)
 	if err != nil {
-		log.Printf("failed saving the pet in the db: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		petError(c, err, "save the pet in the db")
 		return
 	}
 
-	c.JSON(http.StatusOK, pet)
+	responses.Render(c, http.StatusOK, pet)
 }
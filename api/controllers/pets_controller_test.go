@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io/ioutil"
 	"mime/multipart"
@@ -11,6 +12,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
@@ -19,6 +21,8 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/YannHulot/petstore/api/models"
 	"github.com/YannHulot/petstore/api/repository"
+	"github.com/YannHulot/petstore/api/services"
+	"github.com/YannHulot/petstore/api/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/go-test/deep"
 	"github.com/jinzhu/gorm"
@@ -34,6 +38,9 @@ type Suite struct {
 
 	repository repository.PetRepository
 	controller PetController
+
+	// uploadDir is the directory of the storage of the uploaded images
+	uploadDir string
 }
 
 func (s *Suite) SetupSuite() {
@@ -52,8 +59,24 @@ func (s *Suite) SetupSuite() {
 
 	petRepository := repository.NewPetRepository(s.DB)
 
+	s.uploadDir, err = ioutil.TempDir("", "uploads")
+	require.NoError(s.T(), err)
+
+	store, err := storage.NewLocal(s.uploadDir)
+	require.NoError(s.T(), err)
+
 	s.repository = petRepository
-	s.controller = NewPetController(petRepository)
+	// test.png is 419362 bytes and 444x576 pixels
+	s.controller = NewPetController(services.NewPetService(petRepository, nil, store),
+		services.NewImageService(petRepository, store, "http://localhost:8080", services.ImageLimits{
+			MaxSize:      1 << 20,
+			MaxDimension: 1000,
+			MaxPixels:    400000,
+		}))
+}
+
+func (s *Suite) TearDownSuite() {
+	require.NoError(s.T(), os.RemoveAll(s.uploadDir))
 }
 
 func (s *Suite) AfterTest(_, _ string) {
@@ -73,13 +96,13 @@ func TestInit(t *testing.T) {
 	suite.Run(t, new(Suite))
 }
 
-func (s *Suite) Test_controller_FindPetByIDOrStatus_findPetByID() {
+func (s *Suite) Test_controller_FindPetByID() {
 	id := "1"
 	name := "doggie"
 	status := "available"
 
 	r := gin.Default()
-	r.GET("/api/v1/pet/:id", s.controller.FindPetByIDOrStatus)
+	r.GET("/api/v1/pet/:id", s.controller.FindPetByID)
 
 	s.mock.ExpectQuery(regexp.QuoteMeta(
 		`SELECT * FROM "pets" WHERE ("pets"."id" = $1) ORDER BY "pets"."id" ASC LIMIT 1`)).
@@ -129,9 +152,9 @@ func (s *Suite) Test_controller_FindPetByIDOrStatus_findPetByID() {
 	}
 }
 
-func (s *Suite) Test_controller_FindPetByIDOrStatus_findPetByStatus_noStatus() {
+func (s *Suite) Test_controller_FindPetByStatus_noStatus() {
 	r := gin.Default()
-	r.GET("/api/v1/pet/:id", s.controller.FindPetByIDOrStatus)
+	r.GET("/api/v1/pet/findByStatus", s.controller.FindPetByStatus)
 
 	req, err := http.NewRequest("GET", "/api/v1/pet/findByStatus?status=", nil)
 	require.NoError(s.T(), err)
@@ -139,15 +162,15 @@ func (s *Suite) Test_controller_FindPetByIDOrStatus_findPetByStatus_noStatus() {
 	recorder := httptest.NewRecorder()
 	r.ServeHTTP(recorder, req)
 
-	errorResponse := `{"message":"Invalid status value","type":"error"}`
+	errorResponse := `{"code":400,"type":"error","message":"Invalid status value"}`
 
 	require.Nil(s.T(), deep.Equal(recorder.Body.String(), errorResponse))
 	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
 }
 
-func (s *Suite) Test_controller_FindPetByIDOrStatus_findPetByStatus_invalidStatus() {
+func (s *Suite) Test_controller_FindPetByStatus_invalidStatus() {
 	r := gin.Default()
-	r.GET("/api/v1/pet/:id", s.controller.FindPetByIDOrStatus)
+	r.GET("/api/v1/pet/findByStatus", s.controller.FindPetByStatus)
 
 	req, err := http.NewRequest("GET", "/api/v1/pet/findByStatus?status=test", nil)
 	require.NoError(s.T(), err)
@@ -155,33 +178,37 @@ func (s *Suite) Test_controller_FindPetByIDOrStatus_findPetByStatus_invalidStatu
 	recorder := httptest.NewRecorder()
 	r.ServeHTTP(recorder, req)
 
-	errorResponse := `{"message":"Invalid status value","type":"error"}`
+	errorResponse := `{"code":400,"type":"error","message":"Invalid status value"}`
 
 	require.Nil(s.T(), deep.Equal(recorder.Body.String(), errorResponse))
 	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
 }
 
-func (s *Suite) Test_controller_FindPetByIDOrStatus_findPetByStatus_errorInTransaction() {
+func (s *Suite) Test_controller_FindPetByStatus_errorInTransaction() {
 	r := gin.Default()
-	r.GET("/api/v1/pet/:id", s.controller.FindPetByIDOrStatus)
+	r.GET("/api/v1/pet/findByStatus", s.controller.FindPetByStatus)
 	status := "available"
 
 	req, err := http.NewRequest("GET", "/api/v1/pet/findByStatus?status=available", nil)
 	require.NoError(s.T(), err)
 
 	s.mock.ExpectQuery(regexp.QuoteMeta(
-		`SELECT * FROM "pets" WHERE (status = $1) LIMIT 100`)).
-		WithArgs(status).
+		`SELECT * FROM "pets" WHERE (status IN ($1) AND id > $2) ORDER BY id ASC LIMIT 101`)).
+		WithArgs(status, 0).
 		WillReturnError(fmt.Errorf("some error"))
 
 	recorder := httptest.NewRecorder()
 	r.ServeHTTP(recorder, req)
 
-	errorResponse := `{"message":"some error","type":"error"}`
+	errorResponse := `{"code":500,"type":"error","message":"Internal server error"}`
 
 	require.Nil(s.T(), deep.Equal(recorder.Body.String(), errorResponse))
 	require.Nil(s.T(), deep.Equal(recorder.Code, 500))
 
+	// the router does not accept /pet/findByStatus and /pet/:id together
+	r = gin.Default()
+	r.GET("/api/v1/pet/:id", s.controller.FindPetByID)
+
 	req, err = http.NewRequest("GET", "/api/v1/pet/1", nil)
 	require.NoError(s.T(), err)
 
@@ -193,23 +220,23 @@ func (s *Suite) Test_controller_FindPetByIDOrStatus_findPetByStatus_errorInTrans
 	recorder = httptest.NewRecorder()
 	r.ServeHTTP(recorder, req)
 
-	errorResponse = `{"message":"another error","type":"error"}`
+	errorResponse = `{"code":500,"type":"error","message":"Internal server error"}`
 
 	require.Nil(s.T(), deep.Equal(recorder.Body.String(), errorResponse))
 	require.Nil(s.T(), deep.Equal(recorder.Code, 500))
 }
 
-func (s *Suite) Test_controller_FindPetByIDOrStatus_findPetByStatus_singleStatus() {
+func (s *Suite) Test_controller_FindPetByStatus_singleStatus() {
 	id := "1"
 	name := "doggie"
 	status := "available"
 
 	r := gin.Default()
-	r.GET("/api/v1/pet/:id", s.controller.FindPetByIDOrStatus)
+	r.GET("/api/v1/pet/findByStatus", s.controller.FindPetByStatus)
 
 	s.mock.ExpectQuery(regexp.QuoteMeta(
-		`SELECT * FROM "pets" WHERE (status = $1) LIMIT 100`)).
-		WithArgs(status).
+		`SELECT * FROM "pets" WHERE (status IN ($1) AND id > $2) ORDER BY id ASC LIMIT 101`)).
+		WithArgs(status, 0).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).
 			AddRow(id, name, status))
 
@@ -256,48 +283,33 @@ func (s *Suite) Test_controller_FindPetByIDOrStatus_findPetByStatus_singleStatus
 	}
 }
 
-func (s *Suite) Test_controller_FindPetByIDOrStatus_findPetByStatus_multipleStatuses() {
+func (s *Suite) Test_controller_FindPetByStatus_multipleStatuses() {
 	id := "1"
 	name := "doggie"
 	status := "available"
 
 	r := gin.Default()
-	r.GET("/api/v1/pet/:id", s.controller.FindPetByIDOrStatus)
+	r.GET("/api/v1/pet/findByStatus", s.controller.FindPetByStatus)
 
 	s.mock.ExpectQuery(regexp.QuoteMeta(
-		`SELECT * FROM "pets" WHERE (status = $1) LIMIT 100`)).
-		WithArgs(status).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).
-			AddRow(id, name, status))
-
-	s.mock.ExpectQuery(regexp.QuoteMeta(
-		`SELECT * FROM "tags" WHERE ("pet_id" IN ($1))`)).
-		WithArgs(1).
-		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).
-			AddRow(id, "mock-tag-name", 2))
-
-	s.mock.ExpectQuery(regexp.QuoteMeta(
-		`SELECT * FROM "categories" WHERE ("pet_id" IN ($1))`)).
-		WithArgs(1).
-		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).
-			AddRow(id, "mock-category-name", 4))
-
-	s.mock.ExpectQuery(regexp.QuoteMeta(
-		`SELECT * FROM "pets" WHERE (status = $1) LIMIT 100`)).
-		WithArgs("sold").
+		`SELECT * FROM "pets" WHERE (status IN ($1,$2) AND id > $3) ORDER BY id ASC LIMIT 101`)).
+		WithArgs(status, "sold", 0).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).
+			AddRow(id, name, status).
 			AddRow("7", "rover", "sold"))
 
 	s.mock.ExpectQuery(regexp.QuoteMeta(
-		`SELECT * FROM "tags" WHERE ("pet_id" IN ($1))`)).
-		WithArgs(7).
+		`SELECT * FROM "tags" WHERE ("pet_id" IN ($1,$2))`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).
+			AddRow(id, "mock-tag-name", 2).
 			AddRow("7", "mock-tag-name-2", 9))
 
 	s.mock.ExpectQuery(regexp.QuoteMeta(
-		`SELECT * FROM "categories" WHERE ("pet_id" IN ($1))`)).
-		WithArgs(7).
+		`SELECT * FROM "categories" WHERE ("pet_id" IN ($1,$2))`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).
+			AddRow(id, "mock-category-name", 4).
 			AddRow(7, "mock-category-name-2", 10))
 
 	req, err := http.NewRequest("GET", "/api/v1/pet/findByStatus?status=available&status=sold", nil)
@@ -384,6 +396,20 @@ func (s *Suite) Test_UploadFile_success() {
 	r := gin.Default()
 	r.POST("/api/v1/pet/:id/uploadImage", s.controller.UploadFile)
 
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE ("pets"."id" = 1) ORDER BY "pets"."id" ASC LIMIT 1 FOR UPDATE`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "photos_urls", "status"}).
+			AddRow(1, "rex", "{https://example.com/rex.png}", "available"))
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO "pet_images" ("pet_id","key","filename","content_type","size","created_at") VALUES ($1,$2,$3,$4,$5,$6) RETURNING "pet_images"."id"`)).
+		WithArgs(1, sqlmock.AnyArg(), "test.png", "image/png", 419362, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "pets" SET "photos_urls" = $1, "updated_at" = $2 WHERE "pets"."id" = $3`)).
+		WithArgs(pq.StringArray{"https://example.com/rex.png", "http://localhost:8080/api/v1/pet/1/images/7"}, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+
 	req, err := http.NewRequest("POST", "/api/v1/pet/1/uploadImage", body)
 	require.NoError(s.T(), err)
 	req.Header.Add("Content-Type", writer.FormDataContentType())
@@ -391,17 +417,48 @@ func (s *Suite) Test_UploadFile_success() {
 	recorder := httptest.NewRecorder()
 	r.ServeHTTP(recorder, req)
 
-	expectedResponse := `{"message":"additionalMetadata: test\nFile uploaded to ./test.png, 419362 bytes","type":"unknown"}`
+	expectedResponse := `{"code":200,"type":"unknown","message":"additionalMetadata: test\nFile uploaded to http://localhost:8080/api/v1/pet/1/images/7, 419362 bytes"}`
 
 	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
 	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
-	if _, err := os.Stat("./test.png"); os.IsNotExist(err) {
-		require.NoError(s.T(), err)
-	}
 
-	// clean up after test
-	err = os.Remove("test.png")
+	// the file is kept under a generated name in the storage, not under the name sent by the client
+	files, err := filepath.Glob(filepath.Join(s.uploadDir, "pets", "1", "*"))
+	require.NoError(s.T(), err)
+	require.Len(s.T(), files, 1)
+	require.Regexp(s.T(), `^[0-9a-f]{32}$`, filepath.Base(files[0]))
+	require.NoError(s.T(), os.Remove(files[0]))
+
+	_, err = os.Stat("test.png")
+	require.True(s.T(), os.IsNotExist(err))
+}
+
+func (s *Suite) Test_UploadFile_pet_not_found() {
+	body, writer := s.createFileRequestBodyHelper(true)
+
+	r := gin.Default()
+	r.POST("/api/v1/pet/:id/uploadImage", s.controller.UploadFile)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE ("pets"."id" = 2) ORDER BY "pets"."id" ASC LIMIT 1 FOR UPDATE`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	s.mock.ExpectRollback()
+
+	req, err := http.NewRequest("POST", "/api/v1/pet/2/uploadImage", body)
 	require.NoError(s.T(), err)
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 404))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), `{"code":404,"type":"error","message":"Pet not found"}`))
+
+	// the file is removed from the storage when the image cannot be recorded
+	files, err := filepath.Glob(filepath.Join(s.uploadDir, "pets", "2", "*"))
+	require.NoError(s.T(), err)
+	require.Empty(s.T(), files)
 }
 
 func (s *Suite) Test_UploadFile_error_no_metadata() {
@@ -417,7 +474,7 @@ func (s *Suite) Test_UploadFile_error_no_metadata() {
 	recorder := httptest.NewRecorder()
 	r.ServeHTTP(recorder, req)
 
-	expectedResponse := `{"message":"Invalid form value","type":"error"}`
+	expectedResponse := `{"code":400,"type":"error","message":"Invalid form value"}`
 
 	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
 	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
@@ -473,9 +530,23 @@ func (s *Suite) Test_UpdatePetWithFormData_invalid_form_params() {
 	recorder := httptest.NewRecorder()
 	r.ServeHTTP(recorder, req)
 
-	expectedResponse := `{"message":"Invalid input","type":"error"}`
+	expectedResponse := `{"code":400,"type":"error","message":"Invalid input","errors":[{"field":"name","code":"required","message":"name or status must be supplied"}]}`
 
-	require.Nil(s.T(), deep.Equal(recorder.Code, 405))
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+
+	data.Set("status", "taken")
+
+	req, err = http.NewRequest("POST", "/api/v1/pet/1", strings.NewReader(data.Encode()))
+	require.NoError(s.T(), err)
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	expectedResponse = `{"code":400,"type":"error","message":"Invalid input","errors":[{"field":"status","code":"invalid_value","message":"must be one of available, pending or sold"}]}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
 	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
 }
 
@@ -483,7 +554,7 @@ func (s *Suite) Test_UpdatePetWithFormData_success() {
 	var (
 		id     = "5"
 		name   = "good-boy"
-		status = "taken"
+		status = "sold"
 	)
 
 	r := gin.Default()
@@ -502,8 +573,8 @@ func (s *Suite) Test_UpdatePetWithFormData_success() {
 	s.mock.ExpectBegin()
 
 	s.mock.ExpectExec(regexp.QuoteMeta(
-		`UPDATE "pets" SET "name" = $1, "status" = $2 WHERE (id = $3)`)).
-		WithArgs(name, status, id).
+		`UPDATE "pets" SET "name" = $1, "status" = $2, "updated_at" = $3 WHERE (id = $4)`)).
+		WithArgs(name, status, sqlmock.AnyArg(), id).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	s.mock.ExpectCommit()
@@ -529,7 +600,57 @@ func (s *Suite) Test_UpdatePetWithFormData_success() {
 	recorder := httptest.NewRecorder()
 	r.ServeHTTP(recorder, req)
 
-	expectedResponse := `{"id":5,"category":{"id":4,"name":"mock-category-name"},"name":"good-boy","photoUrls":null,"tags":[{"id":2,"name":"mock-tag-name"}],"status":"taken"}`
+	expectedResponse := `{"id":5,"category":{"id":4,"name":"mock-category-name"},"name":"good-boy","photoUrls":null,"tags":[{"id":2,"name":"mock-tag-name"}],"status":"sold"}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+}
+
+func (s *Suite) Test_UpdatePetWithFormData_only_status() {
+	var (
+		id     = "5"
+		status = "sold"
+	)
+
+	r := gin.Default()
+	r.POST("/api/v1/pet/:id", s.controller.UpdatePetWithFormData)
+
+	data := url.Values{}
+	data.Set("status", status)
+
+	req, err := http.NewRequest("POST", "/api/v1/pet/5", strings.NewReader(data.Encode()))
+	require.NoError(s.T(), err)
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	s.mock.ExpectBegin()
+
+	s.mock.ExpectExec(regexp.QuoteMeta(
+		`UPDATE "pets" SET "status" = $1, "updated_at" = $2 WHERE (id = $3)`)).
+		WithArgs(status, sqlmock.AnyArg(), id).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	s.mock.ExpectCommit()
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE ("pets"."id" = $1) ORDER BY "pets"."id" ASC LIMIT 1`)).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).
+			AddRow(id, "good-boy", status))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "tags" WHERE ("pet_id" IN ($1)) ORDER BY "tags"."id`)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "categories" WHERE ("pet_id" IN ($1)) ORDER BY "categories"."id`)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	expectedResponse := `{"id":5,"category":{"id":0,"name":""},"name":"good-boy","photoUrls":null,"tags":[],"status":"sold"}`
 
 	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
 	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
@@ -559,7 +680,7 @@ func (s *Suite) Test_DeletePet_error_no_api_key() {
 	recorder := httptest.NewRecorder()
 	r.ServeHTTP(recorder, req)
 
-	expectedResponse := `{"message":"unauthorized - API key not supplied in Headers","type":"error"}`
+	expectedResponse := `{"code":401,"type":"error","message":"unauthorized - API key not supplied in Headers"}`
 
 	require.Nil(s.T(), deep.Equal(recorder.Code, 401))
 	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
@@ -577,16 +698,12 @@ func (s *Suite) Test_DeletePet_error_pet_not_found() {
 	require.NoError(s.T(), err)
 	req.Header.Add("api_key", "test-key")
 
-	s.mock.ExpectBegin()
-	s.mock.ExpectExec(regexp.QuoteMeta(
-		`DELETE FROM "tags" WHERE (pet_id = $1)`)).
-		WithArgs(id).WillDelayFor(time.Second).
-		WillReturnError(gorm.ErrRecordNotFound)
+	s.expectPetDeleted(id, 0)
 
 	recorder := httptest.NewRecorder()
 	r.ServeHTTP(recorder, req)
 
-	expectedResponse := `{"message":"Pet not found","type":"error"}`
+	expectedResponse := `{"code":404,"type":"error","message":"Pet not found"}`
 
 	require.Nil(s.T(), deep.Equal(recorder.Code, 404))
 	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
@@ -604,31 +721,16 @@ func (s *Suite) Test_DeletePet_success() {
 	require.NoError(s.T(), err)
 	req.Header.Add("api_key", "test-key")
 
-	s.mock.ExpectBegin()
-	s.mock.ExpectExec(regexp.QuoteMeta(
-		`DELETE FROM "tags" WHERE (pet_id = $1)`)).
-		WithArgs(id).WillDelayFor(time.Second).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	s.mock.ExpectCommit()
-
-	s.mock.ExpectBegin()
-	s.mock.ExpectExec(regexp.QuoteMeta(
-		`DELETE FROM "categories" WHERE (pet_id = $1)`)).
-		WithArgs(id).WillDelayFor(time.Second).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	s.mock.ExpectCommit()
-
-	s.mock.ExpectBegin()
-	s.mock.ExpectExec(regexp.QuoteMeta(
-		`DELETE FROM "pets" WHERE (id = $1)`)).
-		WithArgs(id).WillDelayFor(time.Second).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	s.mock.ExpectCommit()
+	// the images of the pet are removed from the storage once it is deleted
+	s.storeImage("0123456789")
+	s.expectPetDeletedWithImages(id, sqlmock.NewRows([]string{"id", "pet_id", "key"}).AddRow(7, 2, imageKey), 1)
 
 	recorder := httptest.NewRecorder()
 	r.ServeHTTP(recorder, req)
 
 	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	_, err = os.Stat(filepath.Join(s.uploadDir, filepath.FromSlash(imageKey)))
+	require.True(s.T(), os.IsNotExist(err))
 }
 
 func (s *Suite) Test_SavePet_error_invalid_payload() {
@@ -648,13 +750,21 @@ func (s *Suite) Test_SavePet_error_invalid_payload() {
 	recorder := httptest.NewRecorder()
 	r.ServeHTTP(recorder, req)
 
-	expectedResponse := `{"message":"Invalid input","type":"error"}`
+	expectedResponse := `{"code":400,"type":"error","message":"Invalid input","errors":[` +
+		`{"field":"name","code":"required","message":"cannot be empty"},` +
+		`{"field":"status","code":"invalid_value","message":"must be one of available, pending or sold"}` +
+		`]}`
 
-	require.Nil(s.T(), deep.Equal(recorder.Code, 405))
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
 	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
 
 	badPet.Name = "Rover"
 	badPet.Status = ""
+	badPet.Tags = []models.Tag{{Name: " "}}
+	badPet.PhotosURLs = pq.StringArray{"https://example.com/rover.png", "rover.png"}
+
+	payload, err = json.Marshal(badPet)
+	require.NoError(s.T(), err)
 
 	req, err = http.NewRequest("POST", "/api/v1/pet", strings.NewReader(string(payload)))
 	require.NoError(s.T(), err)
@@ -662,9 +772,23 @@ func (s *Suite) Test_SavePet_error_invalid_payload() {
 	recorder = httptest.NewRecorder()
 	r.ServeHTTP(recorder, req)
 
-	expectedResponse = `{"message":"Invalid input","type":"error"}`
+	expectedResponse = `{"code":400,"type":"error","message":"Invalid input","errors":[` +
+		`{"field":"tags[0].name","code":"required","message":"cannot be empty"},` +
+		`{"field":"photoUrls[1]","code":"invalid_url","message":"must be an absolute http or https url"}` +
+		`]}`
 
-	require.Nil(s.T(), deep.Equal(recorder.Code, 405))
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+
+	req, err = http.NewRequest("POST", "/api/v1/pet", strings.NewReader("{"))
+	require.NoError(s.T(), err)
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	expectedResponse = `{"code":400,"type":"error","message":"Invalid input","errors":[{"field":"body","code":"invalid_body","message":"is not a valid JSON document"}]}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
 	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
 }
 
@@ -691,7 +815,7 @@ func (s *Suite) Test_SavePet_success() {
 		PetID: 1,
 	}
 
-	urls := pq.StringArray{"test"}
+	urls := pq.StringArray{"https://example.com/doggy.png"}
 
 	goodPet := models.Pet{
 		Name:       name,
@@ -707,8 +831,8 @@ func (s *Suite) Test_SavePet_success() {
 	s.mock.ExpectBegin()
 
 	s.mock.ExpectQuery(regexp.QuoteMeta(
-		`INSERT INTO "pets" ("name","photos_urls","status") VALUES ($1,$2,$3) RETURNING "pets"."id"`)).
-		WithArgs(name, urls, status).
+		`INSERT INTO "pets" ("name","photos_urls","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5) RETURNING "pets"."id"`)).
+		WithArgs(name, urls, status, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "categories" SET "name" = $1, "pet_id" = $2  WHERE "categories"."id" = $3`)).
@@ -735,7 +859,391 @@ func (s *Suite) Test_SavePet_success() {
 	goodPet.ID = 1
 	goodPet.Category.PetID = 0
 	goodPet.Tags[0].PetID = 0
+	goodPet.CreatedAt = savedPet.CreatedAt
+	goodPet.UpdatedAt = savedPet.UpdatedAt
+
+	require.NotNil(s.T(), savedPet.CreatedAt)
 
 	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
 	require.Nil(s.T(), deep.Equal(*savedPet, goodPet))
 }
+
+func (s *Suite) Test_controller_FindPetByStatus_pagination() {
+	r := gin.Default()
+	r.GET("/api/v1/pet/findByStatus", s.controller.FindPetByStatus)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE (status IN ($1,$2) AND id > $3) ORDER BY id ASC LIMIT 3`)).
+		WithArgs("available", "sold", 4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).
+			AddRow(5, "rex", "available").
+			AddRow(7, "rover", "sold").
+			AddRow(9, "fido", "available"))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tags" WHERE ("pet_id" IN ($1,$2,$3))`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE ("pet_id" IN ($1,$2,$3))`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "pets" WHERE (status IN ($1,$2))`)).
+		WithArgs("available", "sold").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(13))
+
+	req, err := http.NewRequest("GET", "/api/v1/pet/findByStatus?status=available&status=sold&limit=2&cursor=4&count=true", nil)
+	require.NoError(s.T(), err)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	var pets []models.Pet
+	err = json.Unmarshal(recorder.Body.Bytes(), &pets)
+	require.NoError(s.T(), err)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Len(s.T(), pets, 2)
+	require.Nil(s.T(), deep.Equal([]uint64{pets[0].ID, pets[1].ID}, []uint64{5, 7}))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("X-Total-Count"), "13"))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("Link"),
+		`</api/v1/pet/findByStatus?count=true&cursor=7&limit=2&status=available&status=sold>; rel="next"`))
+}
+
+func (s *Suite) Test_controller_FindPetByStatus_invalidPagination() {
+	r := gin.Default()
+	r.GET("/api/v1/pet/findByStatus", s.controller.FindPetByStatus)
+
+	for query, message := range map[string]string{
+		"limit=0":       "Invalid limit value",
+		"limit=5000":    "Invalid limit value",
+		"cursor=abc":    "Invalid cursor value",
+		"count=perhaps": "Invalid count value",
+	} {
+		req, err := http.NewRequest("GET", "/api/v1/pet/findByStatus?status=sold&"+query, nil)
+		require.NoError(s.T(), err)
+
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+
+		require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+		require.Nil(s.T(), deep.Equal(recorder.Body.String(), `{"code":400,"type":"error","message":"`+message+`"}`))
+	}
+}
+
+func (s *Suite) Test_controller_FindPetByTags() {
+	r := gin.Default()
+	r.GET("/api/v1/pet/findByTags", s.controller.FindPetByTags)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE (id IN (SELECT pet_id FROM "tags" WHERE (name IN ($1,$2)) GROUP BY pet_id HAVING (COUNT(DISTINCT name) = $3))) AND (id > $4) ORDER BY id ASC LIMIT 101`)).
+		WithArgs("small", "cute", 2, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).
+			AddRow(3, "rex", "available"))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tags" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).
+			AddRow(3, "small", 1).
+			AddRow(3, "cute", 2))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).
+			AddRow(3, "dogs", 4))
+
+	req, err := http.NewRequest("GET", "/api/v1/pet/findByTags?tags=small&tags=cute&match=all", nil)
+	require.NoError(s.T(), err)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	expectedResponse := `[{"id":3,"category":{"id":4,"name":"dogs"},"name":"rex","photoUrls":null,"tags":[{"id":1,"name":"small"},{"id":2,"name":"cute"}],"status":"available"}]`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+}
+
+func (s *Suite) Test_controller_FindPetByTags_invalidParams() {
+	r := gin.Default()
+	r.GET("/api/v1/pet/findByTags", s.controller.FindPetByTags)
+
+	for query, message := range map[string]string{
+		"":                     "Invalid tag value",
+		"tags=":                "Invalid tag value",
+		"tags=small&match=few": "Invalid match value",
+	} {
+		req, err := http.NewRequest("GET", "/api/v1/pet/findByTags?"+query, nil)
+		require.NoError(s.T(), err)
+
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+
+		require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+		require.Nil(s.T(), deep.Equal(recorder.Body.String(), `{"code":400,"type":"error","message":"`+message+`"}`))
+	}
+}
+
+func (s *Suite) Test_controller_SearchPets() {
+	r := gin.Default()
+	r.GET("/api/v1/pets", s.controller.SearchPets)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT count(*) FROM "pets" WHERE (LOWER(name) LIKE $1 ESCAPE '\') AND (status IN ($2))`)).
+		WithArgs("%rex%", "available").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE (LOWER(name) LIKE $1 ESCAPE '\') AND (status IN ($2)) ORDER BY updated_at DESC,name ASC,id ASC LIMIT 5 OFFSET 0`)).
+		WithArgs("%rex%", "available").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).AddRow(3, "Rex", "available"))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tags" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	req, err := http.NewRequest("GET", "/api/v1/pets?name=REX&status=available&sort=-updatedAt,name&limit=5", nil)
+	require.NoError(s.T(), err)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	expectedResponse := `{"total":1,"pets":[{"id":3,"category":{"id":0,"name":""},"name":"Rex","photoUrls":null,"tags":[],"status":"available"}]}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+}
+
+func (s *Suite) Test_controller_SearchPets_invalidParams() {
+	r := gin.Default()
+	r.GET("/api/v1/pets", s.controller.SearchPets)
+
+	for query, message := range map[string]string{
+		"owner=bob":                      "Unknown query parameter: owner",
+		"status=lost":                    "Invalid status value",
+		"sort=name,-price":               "Invalid sort value: price",
+		"sort=name%3B%20DROP%20TABLE":    "Invalid sort value: name; DROP TABLE",
+		"createdAfter=yesterday":         "Invalid createdAfter value, expected an RFC 3339 date",
+		"limit=0":                        "Invalid limit value",
+		"offset=-1":                      "Invalid offset value",
+		"tags=":                          "Invalid tag value",
+		"updatedBefore=2019-13-01T00:00": "Invalid updatedBefore value, expected an RFC 3339 date",
+	} {
+		req, err := http.NewRequest("GET", "/api/v1/pets?"+query, nil)
+		require.NoError(s.T(), err)
+
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+
+		require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+		require.Nil(s.T(), deep.Equal(recorder.Body.String(), `{"code":400,"type":"error","message":"`+message+`"}`))
+	}
+}
+
+func (s *Suite) Test_invalid_id() {
+	r := gin.Default()
+	r.GET("/api/v1/pet/:id", s.controller.FindPetByID)
+	r.POST("/api/v1/pet/:id", s.controller.UpdatePetWithFormData)
+	r.POST("/api/v1/pet/:id/uploadImage", s.controller.UploadFile)
+	r.DELETE("/api/v1/pet/:id", s.controller.DeletePet)
+
+	expectedResponse := `{"code":400,"type":"error","message":"Invalid ID supplied"}`
+
+	for _, id := range []string{"abc", "xfindByStatusx", "0", "-1", "1.5", "99999999999999999999"} {
+		for _, route := range []struct{ method, path string }{
+			{"GET", "/api/v1/pet/" + id},
+			{"POST", "/api/v1/pet/" + id},
+			{"POST", "/api/v1/pet/" + id + "/uploadImage"},
+			{"DELETE", "/api/v1/pet/" + id},
+		} {
+			req, err := http.NewRequest(route.method, route.path, nil)
+			require.NoError(s.T(), err)
+			req.Header.Add("api_key", "test-key")
+
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			require.Nil(s.T(), deep.Equal(recorder.Code, 400), route)
+			require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse), route)
+		}
+	}
+}
+
+func (s *Suite) Test_UpdatePet_error_invalid_payload() {
+	r := gin.Default()
+	r.PUT("/api/v1/pet", s.controller.UpdatePet)
+
+	req, err := http.NewRequest("PUT", "/api/v1/pet", strings.NewReader(`{"name":"Rover","status":"taken"}`))
+	require.NoError(s.T(), err)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	expectedResponse := `{"code":400,"type":"error","message":"Invalid input","errors":[` +
+		`{"field":"id","code":"required","message":"cannot be empty"},` +
+		`{"field":"status","code":"invalid_value","message":"must be one of available, pending or sold"}` +
+		`]}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+}
+
+func (s *Suite) Test_SavePet_xml() {
+	r := gin.Default()
+	r.POST("/api/v1/pet", s.controller.SavePet)
+
+	urls := pq.StringArray{"https://example.com/doggy.png"}
+
+	s.mock.ExpectBegin()
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO "pets" ("name","photos_urls","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5) RETURNING "pets"."id"`)).
+		WithArgs("doggy", urls, "available", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "tags" SET "name" = $1, "pet_id" = $2  WHERE "tags"."id" = $3`)).
+		WithArgs("small", 1, 6).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	s.mock.ExpectCommit()
+
+	payload := `<Pet><name>doggy</name><photoUrls><photoUrl>https://example.com/doggy.png</photoUrl></photoUrls>` +
+		`<tags><Tag><id>6</id><name>small</name></Tag></tags><status>available</status></Pet>`
+
+	req, err := http.NewRequest("POST", "/api/v1/pet", strings.NewReader(payload))
+	require.NoError(s.T(), err)
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Accept", "application/xml")
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("Content-Type"), "application/xml; charset=utf-8"))
+
+	savedPet := models.Pet{}
+	err = xml.Unmarshal(recorder.Body.Bytes(), &savedPet)
+	require.NoError(s.T(), err)
+
+	require.Nil(s.T(), deep.Equal(savedPet.ID, uint64(1)))
+	require.Nil(s.T(), deep.Equal(savedPet.PhotosURLs, urls))
+	require.Nil(s.T(), deep.Equal(savedPet.Tags, []models.Tag{{ID: 6, Name: "small"}}))
+}
+
+func (s *Suite) Test_SavePet_xml_invalid_payload() {
+	r := gin.Default()
+	r.POST("/api/v1/pet", s.controller.SavePet)
+
+	req, err := http.NewRequest("POST", "/api/v1/pet", strings.NewReader(`<Pet><name>`))
+	require.NoError(s.T(), err)
+	req.Header.Set("Content-Type", "text/xml")
+	req.Header.Set("Accept", "application/xml")
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	expectedResponse := `<ApiResponse><code>400</code><type>error</type><message>Invalid input</message>` +
+		`<errors><error><field>body</field><code>invalid_body</code><message>is not a valid XML document</message></error></errors>` +
+		`</ApiResponse>`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+}
+
+func (s *Suite) Test_controller_FindPetByStatus_xml() {
+	r := gin.Default()
+	r.GET("/api/v1/pet/findByStatus", s.controller.FindPetByStatus)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE (status IN ($1) AND id > $2) ORDER BY id ASC LIMIT 101`)).
+		WithArgs("sold", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).
+			AddRow(1, "doggie", "sold"))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tags" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).AddRow(1, "dogs", 4))
+
+	req, err := http.NewRequest("GET", "/api/v1/pet/findByStatus?status=sold", nil)
+	require.NoError(s.T(), err)
+	req.Header.Set("Accept", "application/xml")
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	expectedResponse := `<pets><Pet><id>1</id><Category><id>4</id><name>dogs</name></Category><name>doggie</name>` +
+		`<photoUrls></photoUrls><tags></tags><status>sold</status></Pet></pets>`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+}
+
+func (s *Suite) Test_controller_FindPetByID_lastModified() {
+	r := gin.Default()
+	r.GET("/api/v1/pet/:id", s.controller.FindPetByID)
+
+	createdAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2019, 10, 2, 8, 15, 0, 0, time.UTC)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE ("pets"."id" = $1) ORDER BY "pets"."id" ASC LIMIT 1`)).
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status", "created_at", "updated_at"}).
+			AddRow(1, "doggie", "available", createdAt, updatedAt))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tags" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	req, err := http.NewRequest("GET", "/api/v1/pet/1", nil)
+	require.NoError(s.T(), err)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("Last-Modified"), "Wed, 02 Oct 2019 08:15:00 GMT"))
+}
+
+func (s *Suite) Test_controller_FindPetByStatus_noLastModified() {
+	r := gin.Default()
+	r.GET("/api/v1/pet/findByStatus", s.controller.FindPetByStatus)
+
+	updatedAt := time.Date(2019, 10, 2, 8, 15, 0, 0, time.UTC)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE (status IN ($1) AND id > $2) ORDER BY id ASC LIMIT 101`)).
+		WithArgs("available", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status", "updated_at"}).
+			AddRow(1, "doggie", "available", updatedAt))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tags" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	req, err := http.NewRequest("GET", "/api/v1/pet/findByStatus?status=available", nil)
+	require.NoError(s.T(), err)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	// the date of the pets left in the list would not change when another one is deleted
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("Last-Modified"), ""))
+}
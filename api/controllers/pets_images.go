@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// imageCacheControl lets the clients keep the images, the content of an image never changes since a new upload gets a new id
+const imageCacheControl = "public, max-age=31536000, immutable"
+
+// GetImage will send an image of the pet from the storage, e.g GET /pet/1/images/7.
+// The range requests and the conditional requests are answered by http.ServeContent
+func (p *PetController) GetImage(c *gin.Context) {
+	id, valid := idParam(c)
+	if !valid {
+		return
+	}
+
+	imageID, valid := positiveIntParam(c, "imageId")
+	if !valid {
+		return
+	}
+
+	image, content, err := p.Images.OpenImage(id, imageID)
+	if err != nil {
+		petError(c, err, "open the image")
+		return
+	}
+	defer content.Close()
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", image.ContentType)
+	header.Set("Content-Disposition", contentDisposition(image.Filename))
+	// the browsers must not guess another type than the one detected on upload
+	header.Set("X-Content-Type-Options", "nosniff")
+	// the random part of the key is unique to the content
+	header.Set("ETag", `"`+path.Base(image.Key)+`"`)
+	header.Set("Cache-Control", imageCacheControl)
+
+	var modified time.Time
+	if image.CreatedAt != nil {
+		modified = *image.CreatedAt
+	}
+
+	http.ServeContent(c.Writer, c.Request, "", modified, content)
+}
+
+// contentDisposition will show the image in the browsers, saved under the name of the uploaded file.
+// The names that cannot be sent in a header are left out
+func contentDisposition(filename string) string {
+	if filename != "" {
+		disposition := mime.FormatMediaType("inline", map[string]string{"filename": filename})
+		if disposition != "" {
+			return disposition
+		}
+	}
+
+	return "inline"
+}
@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/go-test/deep"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+const imageKey = "pets/1/9f86d081884c7d659a2feaa0c55ad015"
+
+func (s *Suite) imageRequest(path string, headers map[string]string) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.GET("/api/v1/pet/:id/images/:imageId", s.controller.GetImage)
+
+	req, err := http.NewRequest("GET", path, nil)
+	require.NoError(s.T(), err)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+// imageQuery finds an image of a pet, the images of the deleted pets are not found
+const imageQuery = `SELECT pet_images.* FROM "pet_images" JOIN pets ON pets.id = pet_images.pet_id ` +
+	`WHERE (pet_images.id = $1 AND pet_images.pet_id = $2) ORDER BY "pet_images"."id" ASC LIMIT 1`
+
+func (s *Suite) expectImage(petID string, imageID string, filename string) {
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		imageQuery)).
+		WithArgs(imageID, petID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "pet_id", "key", "filename", "content_type", "size", "created_at"}).
+			AddRow(7, 1, imageKey, filename, "image/png", 10, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+}
+
+func (s *Suite) storeImage(content string) {
+	path := filepath.Join(s.uploadDir, filepath.FromSlash(imageKey))
+	require.NoError(s.T(), os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(s.T(), ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func (s *Suite) Test_GetImage() {
+	s.storeImage("0123456789")
+	defer os.RemoveAll(filepath.Join(s.uploadDir, "pets"))
+
+	s.expectImage("1", "7", "rex.png")
+	recorder := s.imageRequest("/api/v1/pet/1/images/7", nil)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), "0123456789"))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("Content-Type"), "image/png"))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("Content-Disposition"), `inline; filename=rex.png`))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("ETag"), `"9f86d081884c7d659a2feaa0c55ad015"`))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("Last-Modified"), "Thu, 02 Jan 2020 03:04:05 GMT"))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("Accept-Ranges"), "bytes"))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("X-Content-Type-Options"), "nosniff"))
+
+	// a part of the image
+	s.expectImage("1", "7", "rex.png")
+	recorder = s.imageRequest("/api/v1/pet/1/images/7", map[string]string{"Range": "bytes=2-5"})
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 206))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), "2345"))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("Content-Range"), "bytes 2-5/10"))
+
+	s.expectImage("1", "7", "rex.png")
+	recorder = s.imageRequest("/api/v1/pet/1/images/7", map[string]string{"Range": "bytes=20-"})
+	require.Nil(s.T(), deep.Equal(recorder.Code, 416))
+
+	// the image has not changed
+	s.expectImage("1", "7", "rex.png")
+	recorder = s.imageRequest("/api/v1/pet/1/images/7", map[string]string{"If-None-Match": `"9f86d081884c7d659a2feaa0c55ad015"`})
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 304))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), ""))
+
+	// the names that are not plain ASCII are encoded
+	s.expectImage("1", "7", "chat noir é.png")
+	recorder = s.imageRequest("/api/v1/pet/1/images/7", nil)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("Content-Disposition"), `inline; filename*=utf-8''chat%20noir%20%C3%A9.png`))
+}
+
+func (s *Suite) Test_GetImage_not_found() {
+	// the image belongs to another pet, or its pet was deleted
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		imageQuery)).
+		WithArgs("7", "2").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	recorder := s.imageRequest("/api/v1/pet/2/images/7", nil)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 404))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), `{"code":404,"type":"error","message":"Image not found"}`))
+
+	// the image is recorded but missing from the storage
+	s.expectImage("1", "7", "rex.png")
+	recorder = s.imageRequest("/api/v1/pet/1/images/7", nil)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 404))
+
+	recorder = s.imageRequest("/api/v1/pet/1/images/abc", nil)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), `{"code":400,"type":"error","message":"Invalid ID supplied"}`))
+}
+
+// pngHeader will build the start of a PNG image, its signature and its IHDR chunk, enough to read its size
+func pngHeader(width, height uint32) []byte {
+	chunk := []byte("IHDR")
+	chunk = append(chunk, make([]byte, 13)...)
+	binary.BigEndian.PutUint32(chunk[4:8], width)
+	binary.BigEndian.PutUint32(chunk[8:12], height)
+	// 8 bits per channel, RGB
+	chunk[12], chunk[13] = 8, 2
+
+	checksum := make([]byte, 4)
+	binary.BigEndian.PutUint32(checksum, crc32.ChecksumIEEE(chunk))
+
+	// the signature, then the length of the data of the chunk
+	header := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d")
+	header = append(header, chunk...)
+	return append(header, checksum...)
+}
+
+// webpHeader will build the start of a WebP image whose first chunk has the data
+func webpHeader(chunk string, data []byte) []byte {
+	data = append(data, make([]byte, 16)...)
+	header := make([]byte, 20)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(12+len(data)))
+	copy(header[8:], "WEBP"+chunk)
+	binary.LittleEndian.PutUint32(header[16:20], uint32(len(data)))
+
+	return append(header, data...)
+}
+
+func (s *Suite) uploadImage(petID string, filename string, content []byte) *httptest.ResponseRecorder {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", filename)
+	require.NoError(s.T(), err)
+	_, err = part.Write(content)
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), writer.WriteField("additionalMetadata", "test"))
+	require.NoError(s.T(), writer.Close())
+
+	r := gin.Default()
+	r.POST("/api/v1/pet/:id/uploadImage", s.controller.UploadFile)
+
+	req, err := http.NewRequest("POST", "/api/v1/pet/"+petID+"/uploadImage", body)
+	require.NoError(s.T(), err)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func (s *Suite) Test_UploadFile_rejected() {
+	tooLarge := `cannot be larger than 1000 pixels in width or height, or 400000 pixels in total, it is `
+
+	for _, test := range []struct {
+		name     string
+		content  []byte
+		code     int
+		response string
+	}{
+		// the type is detected from the content, not from the name of the file
+		{"rex.png", []byte("<html><script>alert(1)</script></html>"), 415,
+			`{"code":415,"type":"error","message":"The file must be a PNG, JPEG, WebP or GIF image"}`},
+		{"rex.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), 415,
+			`{"code":415,"type":"error","message":"The file must be a PNG, JPEG, WebP or GIF image"}`},
+		{"rex.png", append(pngHeader(10, 10), make([]byte, 1<<20)...), 413,
+			`{"code":413,"type":"error","message":"The file cannot be larger than 1048576 bytes"}`},
+		{"rex.png", []byte("\x89PNG\r\n\x1a\nnot a png"), 400,
+			`{"code":400,"type":"error","message":"Invalid input","errors":[{"field":"file","code":"invalid_value","message":"is not a valid image"}]}`},
+		// a few bytes can describe a huge image, the size is checked before anything is decoded
+		{"bomb.png", pngHeader(100000, 100000), 400,
+			`{"code":400,"type":"error","message":"Invalid input","errors":[{"field":"file","code":"invalid_value","message":"` + tooLarge + `100000x100000"}]}`},
+		{"wide.png", pngHeader(1001, 1), 400,
+			`{"code":400,"type":"error","message":"Invalid input","errors":[{"field":"file","code":"invalid_value","message":"` + tooLarge + `1001x1"}]}`},
+		{"square.png", pngHeader(900, 900), 400,
+			`{"code":400,"type":"error","message":"Invalid input","errors":[{"field":"file","code":"invalid_value","message":"` + tooLarge + `900x900"}]}`},
+		{"lossy.webp", webpHeader("VP8 ", []byte{0, 0, 0, 0x9d, 0x01, 0x2a, 0x88, 0x13, 0x88, 0x13}), 400,
+			`{"code":400,"type":"error","message":"Invalid input","errors":[{"field":"file","code":"invalid_value","message":"` + tooLarge + `5000x5000"}]}`},
+		{"extended.webp", webpHeader("VP8X", []byte{0, 0, 0, 0, 0xcf, 0x07, 0, 0x09, 0, 0}), 400,
+			`{"code":400,"type":"error","message":"Invalid input","errors":[{"field":"file","code":"invalid_value","message":"` + tooLarge + `2000x10"}]}`},
+		{"truncated.webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8L"), 400,
+			`{"code":400,"type":"error","message":"Invalid input","errors":[{"field":"file","code":"invalid_value","message":"is not a valid image"}]}`},
+	} {
+		recorder := s.uploadImage("1", test.name, test.content)
+
+		require.Equal(s.T(), test.code, recorder.Code, test.name)
+		require.Equal(s.T(), test.response, recorder.Body.String(), test.name)
+	}
+
+	// nothing is stored
+	files, err := filepath.Glob(filepath.Join(s.uploadDir, "pets", "1", "*"))
+	require.NoError(s.T(), err)
+	require.Empty(s.T(), files)
+}
+
+func (s *Suite) Test_UploadFile_body_too_large() {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", "rex.png")
+	require.NoError(s.T(), err)
+	_, err = part.Write(append(pngHeader(10, 10), make([]byte, 2<<20)...))
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), writer.Close())
+
+	r := gin.Default()
+	r.POST("/api/v1/pet/:id/uploadImage", s.controller.UploadFile)
+
+	// the handler limits the body without the validator, whether its length is known or not
+	for name, reader := range map[string]io.Reader{
+		"known length":   bytes.NewReader(body.Bytes()),
+		"unknown length": io.MultiReader(bytes.NewReader(body.Bytes())),
+	} {
+		req, err := http.NewRequest("POST", "/api/v1/pet/1/uploadImage", reader)
+		require.NoError(s.T(), err)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+
+		require.Equal(s.T(), http.StatusRequestEntityTooLarge, recorder.Code, name)
+		require.Equal(s.T(), `{"code":413,"type":"error","message":"The body cannot be larger than 1114112 bytes"}`,
+			recorder.Body.String(), name)
+	}
+}
+
+func (s *Suite) Test_UploadFile_webp() {
+	// a lossless image of 10x10 pixels
+	content := webpHeader("VP8L", []byte{0x2f, 0x09, 0x40, 0x02, 0x00})
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE ("pets"."id" = 1) ORDER BY "pets"."id" ASC LIMIT 1 FOR UPDATE`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).AddRow(1, "rex", "available"))
+	s.mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "pet_images"`)).
+		WithArgs(1, sqlmock.AnyArg(), "rex.webp", "image/webp", len(content), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "pets" SET "photos_urls" = $1`)).
+		WithArgs(pq.StringArray{"http://localhost:8080/api/v1/pet/1/images/8"}, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+
+	recorder := s.uploadImage("1", "rex.webp", content)
+	require.Equal(s.T(), http.StatusOK, recorder.Code, recorder.Body.String())
+
+	files, err := filepath.Glob(filepath.Join(s.uploadDir, "pets", "1", "*"))
+	require.NoError(s.T(), err)
+	require.Len(s.T(), files, 1)
+
+	stored, err := ioutil.ReadFile(files[0])
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), content, stored)
+	require.NoError(s.T(), os.Remove(files[0]))
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/YannHulot/petstore/api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// PatchPet will change some fields of a pet, the body is a JSON Merge Patch or a JSON Patch depending on its content type.
+// The patch is applied to the stored pet, including its tags and category, and the result is validated before being saved
+func (p *PetController) PatchPet(c *gin.Context) {
+	id, valid := idParam(c)
+	if !valid {
+		return
+	}
+
+	contentType, patchDocument, valid := readPatch(c)
+	if !valid {
+		return
+	}
+
+	pet, err := p.Service.PatchPet(id, contentType, patchDocument)
+	if err != nil {
+		patchError(c, err, petError)
+		return
+	}
+
+	responses.Render(c, http.StatusOK, pet)
+}
+
+// readPatch will read the content type and the body of a patch request, the other media types are answered with a 415
+func readPatch(c *gin.Context) (string, []byte, bool) {
+	contentType := c.ContentType()
+	if contentType != services.MergePatchContentType && contentType != services.JSONPatchContentType {
+		responses.Error(c, http.StatusUnsupportedMediaType,
+			"Content-Type must be "+services.MergePatchContentType+" or "+services.JSONPatchContentType)
+		return "", nil, false
+	}
+
+	patchDocument, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		log.Printf("failed reading the body of the request: %v", err)
+		responses.Error(c, http.StatusBadRequest, "Invalid input")
+		return "", nil, false
+	}
+
+	return contentType, patchDocument, true
+}
+
+// patchError will reply to the errors that only a patch can cause, the other errors are left to the fallback
+func patchError(c *gin.Context, err error, fallback func(c *gin.Context, err error, action string)) {
+	switch err {
+	case services.ErrInvalidPatch:
+		invalidInput(c, err)
+	case services.ErrPatchConflict:
+		responses.Error(c, http.StatusConflict, "The patch cannot be applied to the pet")
+	default:
+		fallback(c, err, "patch the pet in the db")
+	}
+}
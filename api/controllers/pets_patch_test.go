@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/go-test/deep"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// expectStoredPet expects the pet 5 to be loaded and locked, it is called rex, has the tag small and the category dogs
+func (s *Suite) expectStoredPet() {
+	s.mock.ExpectBegin()
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE ("pets"."id" = $1) ORDER BY "pets"."id" ASC LIMIT 1 FOR UPDATE`)).
+		WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "photos_urls", "status"}).
+			AddRow(5, "rex", "{https://example.com/rex.png}", "available"))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tags" WHERE ("pet_id" = $1)`)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "pet_id"}).AddRow(2, "small", 5))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE ("pet_id" = $1)`)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "pet_id"}).AddRow(4, "dogs", 5))
+}
+
+func (s *Suite) patchPet(contentType string, patch string) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.PATCH("/api/v1/pet/:id", s.controller.PatchPet)
+
+	req, err := http.NewRequest("PATCH", "/api/v1/pet/5", strings.NewReader(patch))
+	require.NoError(s.T(), err)
+	req.Header.Set("Content-Type", contentType)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func (s *Suite) Test_PatchPet_mergePatch() {
+	s.expectStoredPet()
+
+	s.mock.ExpectExec(regexp.QuoteMeta(
+		`UPDATE "pets" SET "name" = $1, "photos_urls" = $2, "status" = $3, "updated_at" = $4 WHERE "pets"."id" = $5`)).
+		WithArgs("rex", pq.StringArray{}, "sold", sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	s.mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "tags" WHERE (pet_id = $1)`)).
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	s.mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "categories" WHERE (pet_id = $1)`)).
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "tags" SET "name" = $1, "pet_id" = $2  WHERE "tags"."id" = $3`)).
+		WithArgs("cute", 5, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "categories" SET "name" = $1, "pet_id" = $2  WHERE "categories"."id" = $3`)).
+		WithArgs("dogs", 5, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE ("pets"."id" = 5) ORDER BY "pets"."id" ASC LIMIT 1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "photos_urls", "status"}).
+			AddRow(5, "rex", "{}", "sold"))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tags" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "pet_id"}).AddRow(3, "cute", 5))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "pet_id"}).AddRow(4, "dogs", 5))
+
+	s.mock.ExpectCommit()
+
+	recorder := s.patchPet("application/merge-patch+json", `{"status":"sold","photoUrls":[],"tags":[{"id":3,"name":"cute"}]}`)
+
+	expectedResponse := `{"id":5,"category":{"id":4,"name":"dogs"},"name":"rex","photoUrls":[],"tags":[{"id":3,"name":"cute"}],"status":"sold"}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+}
+
+func (s *Suite) Test_PatchPet_jsonPatch_testFailed() {
+	s.expectStoredPet()
+	s.mock.ExpectRollback()
+
+	recorder := s.patchPet("application/json-patch+json",
+		`[{"op":"test","path":"/status","value":"pending"},{"op":"replace","path":"/status","value":"sold"}]`)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 409))
+}
+
+func (s *Suite) Test_PatchPet_invalidResult() {
+	s.expectStoredPet()
+	s.mock.ExpectRollback()
+
+	recorder := s.patchPet("application/json-patch+json",
+		`[{"op":"replace","path":"/status","value":"taken"},{"op":"add","path":"/tags/-","value":{"name":""}}]`)
+
+	expectedResponse := `{"code":400,"type":"error","message":"Invalid input","errors":[` +
+		`{"field":"status","code":"invalid_value","message":"must be one of available, pending or sold"},` +
+		`{"field":"tags[1].name","code":"required","message":"cannot be empty"}]}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+}
+
+func (s *Suite) Test_PatchPet_changedID() {
+	s.expectStoredPet()
+	s.mock.ExpectRollback()
+
+	recorder := s.patchPet("application/merge-patch+json", `{"id":6}`)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+}
+
+func (s *Suite) Test_PatchPet_invalidPatch() {
+	s.expectStoredPet()
+	s.mock.ExpectRollback()
+
+	recorder := s.patchPet("application/json-patch+json", `{"op":"replace"}`)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+}
+
+func (s *Suite) Test_PatchPet_notFound() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "pets"`)).
+		WithArgs("5").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	s.mock.ExpectRollback()
+
+	recorder := s.patchPet("application/merge-patch+json", `{"status":"sold"}`)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 404))
+}
+
+func (s *Suite) Test_PatchPet_unsupportedContentType() {
+	recorder := s.patchPet("application/json", `{"status":"sold"}`)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 415))
+}
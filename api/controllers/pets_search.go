@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSearchQueryLength is the maximum length of the text of a full text search
+const maxSearchQueryLength = 200
+
+// searchParams are the query params accepted by the search, any other param is rejected
+var searchParams = map[string]bool{
+	"q":             true,
+	"name":          true,
+	"category":      true,
+	"tags":          true,
+	"status":        true,
+	"createdAfter":  true,
+	"createdBefore": true,
+	"updatedAfter":  true,
+	"updatedBefore": true,
+	"sort":          true,
+	"limit":         true,
+	"offset":        true,
+	"facets":        true,
+}
+
+// SearchPets will find the pets matching the filters in the query params
+// e.g /pets?q=fluffy+small+cat or /pets?name=rex&tags=small&status=available&sort=-updatedAt,name&limit=20&offset=40
+func (p *PetController) SearchPets(c *gin.Context) {
+	search, err := parsePetSearch(c)
+	if err != nil {
+		log.Printf("invalid search: %v", err)
+		responses.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := p.Service.SearchPets(search)
+	if err != nil {
+		log.Printf("failed to search the pets in the db: %v", err)
+		responses.InternalError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// parsePetSearch will read and validate the search from the query params
+func parsePetSearch(c *gin.Context) (models.PetSearch, error) {
+	query := c.Request.URL.Query()
+	search := models.PetSearch{Limit: defaultPageLimit}
+
+	for param := range query {
+		if !searchParams[param] {
+			return models.PetSearch{}, fmt.Errorf("Unknown query parameter: %s", param)
+		}
+	}
+
+	search.Query = strings.TrimSpace(query.Get("q"))
+	if len(search.Query) > maxSearchQueryLength {
+		return models.PetSearch{}, fmt.Errorf("Invalid q value, it cannot be longer than %d characters", maxSearchQueryLength)
+	}
+
+	search.Name = strings.TrimSpace(query.Get("name"))
+	search.Category = strings.TrimSpace(query.Get("category"))
+
+	for _, tag := range query["tags"] {
+		if strings.TrimSpace(tag) == "" {
+			return models.PetSearch{}, fmt.Errorf("Invalid tag value")
+		}
+		search.Tags = append(search.Tags, tag)
+	}
+
+	for _, status := range query["status"] {
+		if !models.PetStatuses[status] {
+			return models.PetSearch{}, fmt.Errorf("Invalid status value")
+		}
+		search.Statuses = append(search.Statuses, status)
+	}
+
+	dates := map[string]**time.Time{
+		"createdAfter":  &search.CreatedAfter,
+		"createdBefore": &search.CreatedBefore,
+		"updatedAfter":  &search.UpdatedAfter,
+		"updatedBefore": &search.UpdatedBefore,
+	}
+	for param, target := range dates {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return models.PetSearch{}, fmt.Errorf("Invalid %s value, expected an RFC 3339 date", param)
+		}
+		*target = &parsed
+	}
+
+	sort, err := parseSort(query.Get("sort"))
+	if err != nil {
+		return models.PetSearch{}, err
+	}
+	search.Sort = sort
+
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > maxPageLimit {
+			return models.PetSearch{}, fmt.Errorf("Invalid limit value")
+		}
+		search.Limit = parsed
+	}
+
+	if offset := query.Get("offset"); offset != "" {
+		parsed, err := strconv.Atoi(offset)
+		if err != nil || parsed < 0 {
+			return models.PetSearch{}, fmt.Errorf("Invalid offset value")
+		}
+		search.Offset = parsed
+	}
+
+	if facets := query.Get("facets"); facets != "" {
+		parsed, err := strconv.ParseBool(facets)
+		if err != nil {
+			return models.PetSearch{}, fmt.Errorf("Invalid facets value")
+		}
+		search.Facets = parsed
+	}
+
+	return search, nil
+}
+
+// parseSort will read a list of fields separated by commas, a field starting with "-" is sorted in descending order
+func parseSort(value string) ([]models.SortField, error) {
+	var fields []models.SortField
+
+	if value == "" {
+		return fields, nil
+	}
+
+	for _, field := range strings.Split(value, ",") {
+		sortField := models.SortField{Field: strings.TrimSpace(field)}
+		if strings.HasPrefix(sortField.Field, "-") {
+			sortField.Field = strings.TrimPrefix(sortField.Field, "-")
+			sortField.Descending = true
+		}
+
+		if !models.PetSortFields[sortField.Field] {
+			return nil, fmt.Errorf("Invalid sort value: %s", sortField.Field)
+		}
+
+		fields = append(fields, sortField)
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/YannHulot/petstore/api/caching"
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/YannHulot/petstore/api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// PetV2Controller is a wrapper for the handlers of the v2 API, the pets are resources at /pets and /pets/{id}.
+// The malformed requests are answered with a 400, the pets that cannot be saved with a 422
+// and the pets that do not exist with a 404
+type PetV2Controller struct {
+	Service *services.PetService
+}
+
+// NewPetV2Controller will create a new PetV2Controller
+func NewPetV2Controller(service *services.PetService) PetV2Controller {
+	return PetV2Controller{
+		Service: service,
+	}
+}
+
+// petV2Error will reply with the error returned by the service
+func petV2Error(c *gin.Context, err error, action string) {
+	if errs, ok := err.(models.ValidationErrors); ok {
+		responses.UnprocessableEntity(c, errs)
+		return
+	}
+
+	if err == services.ErrPetNotFound {
+		responses.Error(c, http.StatusNotFound, "Pet not found")
+		return
+	}
+
+	log.Printf("failed to %s: %v", action, err)
+	responses.InternalError(c)
+}
+
+// ListPets will find the pets matching the filters in the query params, they are the ones of the v1 search
+// e.g /pets?status=available&tags=small&sort=-updatedAt&limit=20
+func (p *PetV2Controller) ListPets(c *gin.Context) {
+	search, err := parsePetSearch(c)
+	if err != nil {
+		log.Printf("invalid search: %v", err)
+		responses.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := p.Service.SearchPets(search)
+	if err != nil {
+		petV2Error(c, err, "search the pets in the db")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// CreatePet will save a new pet, its url is sent in the Location header
+func (p *PetV2Controller) CreatePet(c *gin.Context) {
+	var petToSave models.Pet
+
+	err := responses.Bind(c, &petToSave)
+	if err != nil {
+		log.Printf("failed parsing the body of the request: %v", err)
+		invalidInput(c, err)
+		return
+	}
+
+	pet, err := p.Service.CreatePet(&petToSave)
+	if err != nil {
+		petV2Error(c, err, "save the pet in the db")
+		return
+	}
+
+	c.Header("Location", c.Request.URL.Path+"/"+strconv.FormatUint(pet.ID, 10))
+	responses.Render(c, http.StatusCreated, pet)
+}
+
+// GetPet will find a pet by its id
+func (p *PetV2Controller) GetPet(c *gin.Context) {
+	id, valid := idParam(c)
+	if !valid {
+		return
+	}
+
+	pet, err := p.Service.GetPet(id)
+	if err != nil {
+		petV2Error(c, err, "find the pet in the db")
+		return
+	}
+
+	caching.SetLastModified(c, lastModified(*pet))
+	responses.Render(c, http.StatusOK, pet)
+}
+
+// ReplacePet will replace the pet, including its tags and category, with the body of the request
+func (p *PetV2Controller) ReplacePet(c *gin.Context) {
+	id, valid := idParam(c)
+	if !valid {
+		return
+	}
+
+	var petToSave models.Pet
+
+	err := responses.Bind(c, &petToSave)
+	if err != nil {
+		log.Printf("failed parsing the body of the request: %v", err)
+		invalidInput(c, err)
+		return
+	}
+
+	pet, err := p.Service.ReplacePet(id, &petToSave)
+	if err != nil {
+		petV2Error(c, err, "replace the pet in the db")
+		return
+	}
+
+	responses.Render(c, http.StatusOK, pet)
+}
+
+// PatchPet will change some fields of a pet with a JSON Merge Patch or a JSON Patch, like the v1 API
+func (p *PetV2Controller) PatchPet(c *gin.Context) {
+	id, valid := idParam(c)
+	if !valid {
+		return
+	}
+
+	contentType, patchDocument, valid := readPatch(c)
+	if !valid {
+		return
+	}
+
+	pet, err := p.Service.PatchPet(id, contentType, patchDocument)
+	if err != nil {
+		patchError(c, err, petV2Error)
+		return
+	}
+
+	responses.Render(c, http.StatusOK, pet)
+}
+
+// DeletePet will delete a pet, its tags and its category
+func (p *PetV2Controller) DeletePet(c *gin.Context) {
+	if !requireAPIKey(c) {
+		return
+	}
+
+	id, valid := idParam(c)
+	if !valid {
+		return
+	}
+
+	err := p.Service.DeletePet(id)
+	if err != nil {
+		petV2Error(c, err, "delete the pet or associated records in the db")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
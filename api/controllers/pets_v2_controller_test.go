@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-test/deep"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *Suite) v2Router() *gin.Engine {
+	controller := NewPetV2Controller(services.NewPetService(s.repository, nil, nil))
+
+	r := gin.Default()
+	r.POST("/api/v2/pets", controller.CreatePet)
+	r.GET("/api/v2/pets/:id", controller.GetPet)
+	r.DELETE("/api/v2/pets/:id", controller.DeletePet)
+
+	return r
+}
+
+func (s *Suite) v2Request(method string, path string, body io.Reader) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, path, body)
+	require.NoError(s.T(), err)
+	req.Header.Add("api_key", "test-key")
+
+	recorder := httptest.NewRecorder()
+	s.v2Router().ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func (s *Suite) Test_v2_CreatePet_success() {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO "pets" ("name","photos_urls","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5) RETURNING "pets"."id"`)).
+		WithArgs("rex", sqlmock.AnyArg(), "available", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	s.mock.ExpectCommit()
+
+	recorder := s.v2Request("POST", "/api/v2/pets", strings.NewReader(`{"name":"rex","status":"available"}`))
+
+	savedPet := &models.Pet{}
+	err := json.Unmarshal(recorder.Body.Bytes(), savedPet)
+	require.NoError(s.T(), err)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 201))
+	require.Nil(s.T(), deep.Equal(recorder.Header().Get("Location"), "/api/v2/pets/7"))
+	require.Nil(s.T(), deep.Equal(savedPet.ID, uint64(7)))
+}
+
+func (s *Suite) Test_v2_CreatePet_errors() {
+	// a well formed pet that is not valid cannot be processed
+	recorder := s.v2Request("POST", "/api/v2/pets", strings.NewReader(`{"status":"lost"}`))
+
+	expectedResponse := `{"code":422,"type":"error","message":"Invalid input","errors":[` +
+		`{"field":"name","code":"required","message":"cannot be empty"},` +
+		`{"field":"status","code":"invalid_value","message":"must be one of available, pending or sold"}` +
+		`]}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 422))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+
+	// a malformed body is a bad request
+	recorder = s.v2Request("POST", "/api/v2/pets", strings.NewReader(`{`))
+
+	expectedResponse = `{"code":400,"type":"error","message":"Invalid input","errors":[{"field":"body","code":"invalid_body","message":"is not a valid JSON document"}]}`
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), expectedResponse))
+}
+
+func (s *Suite) Test_v2_GetPet_notFound() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE ("pets"."id" = $1) ORDER BY "pets"."id" ASC LIMIT 1`)).
+		WithArgs("3").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	recorder := s.v2Request("GET", "/api/v2/pets/3", nil)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 404))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), `{"code":404,"type":"error","message":"Pet not found"}`))
+}
+
+// expectPetDeleted expects the transaction of the deletion of a pet without images, it is rolled back when no pet is deleted
+func (s *Suite) expectPetDeleted(id string, rows int64) {
+	s.expectPetDeletedWithImages(id, sqlmock.NewRows([]string{"id"}), rows)
+}
+
+func (s *Suite) expectPetDeletedWithImages(id string, images *sqlmock.Rows, rows int64) {
+	s.mock.ExpectBegin()
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "pet_images" WHERE (pet_id = $1)`)).
+		WithArgs(id).
+		WillReturnRows(images)
+	for _, table := range []string{"pet_images", "tags", "categories"} {
+		s.mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "` + table + `" WHERE (pet_id = $1)`)).
+			WithArgs(id).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	s.mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "pets" WHERE (id = $1)`)).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, rows))
+
+	if rows == 0 {
+		s.mock.ExpectRollback()
+	} else {
+		s.mock.ExpectCommit()
+	}
+}
+
+func (s *Suite) Test_v2_DeletePet() {
+	s.expectPetDeleted("2", 1)
+
+	recorder := s.v2Request("DELETE", "/api/v2/pets/2", nil)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 204))
+	require.Nil(s.T(), deep.Equal(recorder.Body.Len(), 0))
+
+	s.expectPetDeleted("2", 0)
+
+	recorder = s.v2Request("DELETE", "/api/v2/pets/2", nil)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 404))
+}
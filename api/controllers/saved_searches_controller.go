@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/repository"
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// SavedSearchController is a wrapper for the handlers of the saved searches
+type SavedSearchController struct {
+	Repository repository.SavedSearchRepository
+}
+
+// NewSavedSearchController will create a new SavedSearchController
+func NewSavedSearchController(repository repository.SavedSearchRepository) SavedSearchController {
+	return SavedSearchController{
+		Repository: repository,
+	}
+}
+
+// SaveSearch will save a search, its owner is notified when a matching pet is available.
+// The search belongs to the api_key of the request, only the same key can find or delete it
+func (s *SavedSearchController) SaveSearch(c *gin.Context) {
+	var searchToSave models.SavedSearch
+
+	owner, valid := apiKeyOwner(c)
+	if !valid {
+		return
+	}
+
+	err := c.ShouldBindJSON(&searchToSave)
+	if err != nil {
+		log.Printf("failed parsing the body of the request: %v", err)
+		responses.Error(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	searchToSave.Sanitise()
+	searchToSave.Owner = owner
+
+	err = searchToSave.Validate()
+	if err != nil {
+		invalidInput(c, err)
+		return
+	}
+
+	search, err := s.Repository.SaveSearch(&searchToSave)
+	if err != nil {
+		log.Printf("failed saving the search in the db: %v", err)
+		responses.InternalError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, search)
+}
+
+// FindSavedSearchByID will find a single saved search of the api_key, the searches of the other keys are not found
+func (s *SavedSearchController) FindSavedSearchByID(c *gin.Context) {
+	owner, valid := apiKeyOwner(c)
+	if !valid {
+		return
+	}
+
+	id, valid := idParam(c)
+	if !valid {
+		return
+	}
+
+	search, err := s.Repository.FindSavedSearchByID(id, owner)
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			responses.Error(c, http.StatusNotFound, "Saved search not found")
+			return
+		}
+		log.Printf("failed to find the saved search in the db: %v", err)
+		responses.InternalError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, search)
+}
+
+// DeleteSavedSearch will delete a saved search of the api_key, its owner will not be notified anymore.
+// The searches of the other keys are not found
+func (s *SavedSearchController) DeleteSavedSearch(c *gin.Context) {
+	owner, valid := apiKeyOwner(c)
+	if !valid {
+		return
+	}
+
+	id, valid := idParam(c)
+	if !valid {
+		return
+	}
+
+	err := s.Repository.DeleteSavedSearch(id, owner)
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			responses.Error(c, http.StatusNotFound, "Saved search not found")
+			return
+		}
+		log.Printf("failed to delete the saved search in the db: %v", err)
+		responses.InternalError(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,265 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/repository"
+	"github.com/YannHulot/petstore/api/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-test/deep"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// keyOwner is the owner of the searches saved with the api_key "key"
+const keyOwner = "2c70e12b7a0646f92279f427c7b38e7334d8e5389cff167a1dc30e73f826b683"
+
+// fakeListener remembers the pets it has been told about
+type fakeListener struct {
+	pets []models.Pet
+}
+
+func (f *fakeListener) PetSaved(pet models.Pet) {
+	f.pets = append(f.pets, pet)
+}
+
+func (s *Suite) Test_SavePet_notifies_listener() {
+	listener := &fakeListener{}
+	controller := NewPetController(services.NewPetService(s.repository, listener, nil), nil)
+
+	r := gin.Default()
+	r.POST("/api/v1/pet", controller.SavePet)
+
+	s.mock.ExpectBegin()
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO "pets" ("name","photos_urls","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5) RETURNING "pets"."id"`)).
+		WithArgs("doggy", pq.StringArray{}, "available", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	s.mock.ExpectCommit()
+
+	req, err := http.NewRequest("POST", "/api/v1/pet", strings.NewReader(`{"name":"doggy","photoUrls":[],"status":"available"}`))
+	require.NoError(s.T(), err)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Len(s.T(), listener.pets, 1)
+	require.Nil(s.T(), deep.Equal(listener.pets[0].ID, uint64(1)))
+	require.Nil(s.T(), deep.Equal(listener.pets[0].Name, "doggy"))
+}
+
+func (s *Suite) Test_SavePet_error_does_not_notify_listener() {
+	listener := &fakeListener{}
+	controller := NewPetController(services.NewPetService(s.repository, listener, nil), nil)
+
+	r := gin.Default()
+	r.POST("/api/v1/pet", controller.SavePet)
+
+	req, err := http.NewRequest("POST", "/api/v1/pet", strings.NewReader(`{"status":"available"}`))
+	require.NoError(s.T(), err)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+	require.Empty(s.T(), listener.pets)
+}
+
+func (s *Suite) Test_SaveSearch_success() {
+	controller := NewSavedSearchController(repository.NewSavedSearchRepository(s.DB))
+
+	r := gin.Default()
+	r.POST("/api/v1/savedSearches", controller.SaveSearch)
+
+	s.mock.ExpectBegin()
+
+	// the search belongs to a hash of the api_key, the owner sent in the payload is ignored
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO "saved_searches" ("email","category","tags","created_at","owner") VALUES ($1,$2,$3,$4,$5) RETURNING "saved_searches"."id"`)).
+		WithArgs("adopter@example.com", "dogs", pq.StringArray{"small"}, sqlmock.AnyArg(), keyOwner).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	s.mock.ExpectCommit()
+
+	payload := `{"id":42,"email":" adopter@example.com ","category":"dogs","tags":["small"," "],"owner":"someone"}`
+	req, err := http.NewRequest("POST", "/api/v1/savedSearches", strings.NewReader(payload))
+	require.NoError(s.T(), err)
+	req.Header.Set("api_key", "key")
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	search := models.SavedSearch{}
+	err = json.Unmarshal(recorder.Body.Bytes(), &search)
+	require.NoError(s.T(), err)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(search.ID, uint64(3)))
+	require.Nil(s.T(), deep.Equal(search.Email, "adopter@example.com"))
+	require.Nil(s.T(), deep.Equal(search.Tags, pq.StringArray{"small"}))
+	require.NotContains(s.T(), recorder.Body.String(), "owner")
+
+	// a search without an api_key would belong to nobody
+	req, err = http.NewRequest("POST", "/api/v1/savedSearches", strings.NewReader(payload))
+	require.NoError(s.T(), err)
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 401))
+}
+
+func (s *Suite) Test_SaveSearch_invalid_email() {
+	controller := NewSavedSearchController(repository.NewSavedSearchRepository(s.DB))
+
+	r := gin.Default()
+	r.POST("/api/v1/savedSearches", controller.SaveSearch)
+
+	for _, payload := range []string{`{"category":"dogs"}`, `{"email":"not an email"}`, `{"email":"Adopter <adopter@example.com>"}`} {
+		req, err := http.NewRequest("POST", "/api/v1/savedSearches", strings.NewReader(payload))
+		require.NoError(s.T(), err)
+		req.Header.Set("api_key", "key")
+
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+
+		require.Nil(s.T(), deep.Equal(recorder.Code, 400), payload)
+	}
+
+	// the values that do not fit in the columns are reported with the other invalid fields
+	payload := `{"email":"adopter","category":"` + strings.Repeat("c", 256) + `"}`
+	req, err := http.NewRequest("POST", "/api/v1/savedSearches", strings.NewReader(payload))
+	require.NoError(s.T(), err)
+	req.Header.Set("api_key", "key")
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), `{"code":400,"type":"error","message":"Invalid input","errors":[`+
+		`{"field":"email","code":"invalid_value","message":"must be a valid email address"},`+
+		`{"field":"category","code":"too_long","message":"cannot be longer than 255 characters"}]}`))
+}
+
+func (s *Suite) Test_FindSavedSearchByID() {
+	controller := NewSavedSearchController(repository.NewSavedSearchRepository(s.DB))
+
+	r := gin.Default()
+	r.GET("/api/v1/savedSearches/:id", controller.FindSavedSearchByID)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "saved_searches" WHERE (id = $1 AND owner = $2) ORDER BY "saved_searches"."id" ASC LIMIT 1`)).
+		WithArgs("7", keyOwner).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "category", "tags", "owner"}).
+			AddRow(7, "adopter@example.com", "dogs", "{small}", keyOwner))
+
+	req, err := http.NewRequest("GET", "/api/v1/savedSearches/7", nil)
+	require.NoError(s.T(), err)
+	req.Header.Set("api_key", "key")
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+	require.Nil(s.T(), deep.Equal(recorder.Body.String(), `{"id":7,"email":"adopter@example.com","category":"dogs","tags":["small"]}`))
+}
+
+func (s *Suite) Test_FindSavedSearchByID_not_found() {
+	controller := NewSavedSearchController(repository.NewSavedSearchRepository(s.DB))
+
+	r := gin.Default()
+	r.GET("/api/v1/savedSearches/:id", controller.FindSavedSearchByID)
+
+	// the search does not exist or belongs to another api_key
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "saved_searches" WHERE (id = $1 AND owner = $2) ORDER BY "saved_searches"."id" ASC LIMIT 1`)).
+		WithArgs("7", keyOwner).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	req, err := http.NewRequest("GET", "/api/v1/savedSearches/7", nil)
+	require.NoError(s.T(), err)
+	req.Header.Set("api_key", "key")
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 404))
+
+	req, err = http.NewRequest("GET", "/api/v1/savedSearches/seven", nil)
+	require.NoError(s.T(), err)
+	req.Header.Set("api_key", "key")
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 400))
+
+	req, err = http.NewRequest("GET", "/api/v1/savedSearches/7", nil)
+	require.NoError(s.T(), err)
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 401))
+}
+
+func (s *Suite) Test_DeleteSavedSearch() {
+	controller := NewSavedSearchController(repository.NewSavedSearchRepository(s.DB))
+
+	r := gin.Default()
+	r.DELETE("/api/v1/savedSearches/:id", controller.DeleteSavedSearch)
+
+	req, err := http.NewRequest("DELETE", "/api/v1/savedSearches/7", nil)
+	require.NoError(s.T(), err)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 401))
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "saved_searches" WHERE (id = $1 AND owner = $2)`)).
+		WithArgs("7", keyOwner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "saved_search_notifications" WHERE (saved_search_id = $1)`)).
+		WithArgs("7").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	s.mock.ExpectCommit()
+
+	req, err = http.NewRequest("DELETE", "/api/v1/savedSearches/7", nil)
+	require.NoError(s.T(), err)
+	req.Header.Set("api_key", "key")
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 200))
+
+	// the search of another api_key is not found
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "saved_searches" WHERE (id = $1 AND owner = $2)`)).
+		WithArgs("7", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectCommit()
+
+	req, err = http.NewRequest("DELETE", "/api/v1/savedSearches/7", nil)
+	require.NoError(s.T(), err)
+	req.Header.Set("api_key", "another key")
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	require.Nil(s.T(), deep.Equal(recorder.Code, 404))
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/gin-gonic/gin"
+)
+
+// invalidInput will reply with a 400 and the list of the invalid fields.
+// Any error that is not a models.ValidationErrors means that the body of the request could not be parsed
+func invalidInput(c *gin.Context, err error) {
+	errs, ok := err.(models.ValidationErrors)
+	if !ok {
+		message := "is not a valid JSON document"
+		if responses.SentXML(c) {
+			message = "is not a valid XML document"
+		}
+
+		errs = models.ValidationErrors{{
+			Field:   "body",
+			Code:    models.CodeInvalidBody,
+			Message: message,
+		}}
+	}
+
+	responses.ValidationError(c, errs)
+}
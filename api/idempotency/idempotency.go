@@ -0,0 +1,281 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// HeaderName is the header the clients send the key of a request in
+	HeaderName = "Idempotency-Key"
+	// ReplayedHeaderName is set on the responses that are replayed from the store
+	ReplayedHeaderName = "Idempotent-Replayed"
+
+	// maxKeyLength is the maximum length of a key, the clients are expected to send UUIDs
+	maxKeyLength = 255
+)
+
+// response is a response recorded to be replayed
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// entry is a key sent by a client, the response is nil while the first request is being handled
+type entry struct {
+	fingerprint string
+	response    *response
+	expires     time.Time
+}
+
+// Store keeps the responses of the requests sent with an Idempotency-Key in memory.
+// The keys are scoped by a hash of the API key of the client, so two clients cannot see the responses of each other
+// and the API keys are not kept.
+// Every instance of the API has its own store, a retry that reaches another instance is handled as a new request
+type Store struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewStore will create a store that keeps the responses for the ttl
+func NewStore(ttl time.Duration) *Store {
+	return &Store{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]*entry{},
+		stop:    make(chan struct{}),
+	}
+}
+
+// StartSweeps will remove the expired responses in the background at every interval until Close is called
+func (s *Store) StartSweeps(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.sweep()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close will stop the sweeps
+func (s *Store) Close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// sweep will remove the responses that have expired
+func (s *Store) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for k, e := range s.entries {
+		if e.expired(now) {
+			delete(s.entries, k)
+		}
+	}
+}
+
+// expired tells if the response of the entry should not be replayed anymore, the entries in flight do not expire
+func (e *entry) expired(now time.Time) bool {
+	return e.response != nil && !now.Before(e.expires)
+}
+
+// begin will return the entry of the key, or record a new one that is in flight when there is none.
+// The entry is nil when the request is new, an entry that expired since the last sweep is replaced
+func (s *Store) begin(key string, fingerprint string) *entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok && !e.expired(s.now()) {
+		return e
+	}
+
+	s.entries[key] = &entry{fingerprint: fingerprint}
+	return nil
+}
+
+// finish will keep the response of the key until it expires
+func (s *Store) finish(key string, r *response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entries[key]
+	e.response = r
+	e.expires = s.now().Add(s.ttl)
+}
+
+// abandon will forget a key whose request failed, so that it can be retried
+func (s *Store) abandon(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+}
+
+// Idempotent will replay the stored response of the requests that have an Idempotency-Key already seen.
+// The same key with another method, path, query or body is answered with a 422, and a key whose first request is still
+// being handled with a 409. The requests without a key, and those that fail with a 5xx, are not stored.
+// The anonymous callers cannot be told apart, so a key sent without an api_key is answered with a 400
+func Idempotent(store *Store, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(HeaderName)
+		if idempotencyKey == "" {
+			handler(c)
+			return
+		}
+
+		apiKey := c.GetHeader("api_key")
+		if apiKey == "" {
+			responses.ValidationError(c, models.ValidationErrors{{
+				Field:   "api_key",
+				Code:    models.CodeRequired,
+				Message: "is required with an " + HeaderName,
+			}})
+			return
+		}
+
+		if len(idempotencyKey) > maxKeyLength {
+			responses.ValidationError(c, models.ValidationErrors{{
+				Field:   HeaderName,
+				Code:    models.CodeTooLong,
+				Message: "cannot be longer than 255 characters",
+			}})
+			return
+		}
+
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			log.Printf("failed reading the body of the request: %v", err)
+			responses.Error(c, http.StatusBadRequest, "Invalid input")
+			return
+		}
+		// the handler reads the body again
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		key := ownerOf(apiKey) + "\x00" + idempotencyKey
+		fingerprint := fingerprintOf(c.Request, body)
+
+		existing := store.begin(key, fingerprint)
+		switch {
+		case existing == nil:
+			handle(c, store, key, handler)
+		case existing.fingerprint != fingerprint:
+			responses.Error(c, http.StatusUnprocessableEntity, HeaderName+" has already been used with another request")
+		case existing.response == nil:
+			c.Header("Retry-After", "1")
+			responses.Error(c, http.StatusConflict, "A request with the same "+HeaderName+" is being processed")
+		default:
+			replay(c, existing.response)
+		}
+	}
+}
+
+// handle will run the handler and store its response.
+// The key is forgotten when the handler fails or panics, the client can then retry with it
+func handle(c *gin.Context, store *Store, key string, handler gin.HandlerFunc) {
+	writer := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+
+	finished := false
+	defer func() {
+		c.Writer = writer.ResponseWriter
+		if !finished {
+			store.abandon(key)
+		}
+	}()
+
+	handler(c)
+
+	status := writer.Status()
+	if status >= http.StatusInternalServerError {
+		return
+	}
+
+	store.finish(key, &response{
+		status: status,
+		header: cloneHeader(writer.Header()),
+		body:   writer.body.Bytes(),
+	})
+	finished = true
+}
+
+// replay will send a stored response as it was sent the first time
+func replay(c *gin.Context, r *response) {
+	header := c.Writer.Header()
+	for name, values := range r.header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set(ReplayedHeaderName, "true")
+
+	c.Writer.WriteHeader(r.status)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Write(r.body)
+	c.Abort()
+}
+
+// ownerOf will return a hash of the API key, like the owner of the saved searches, the key itself is never stored
+func ownerOf(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// fingerprintOf identifies a request by its method, its path, its query and its body
+func fingerprintOf(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func cloneHeader(header http.Header) http.Header {
+	clone := make(http.Header, len(header))
+	for name, values := range header {
+		clone[name] = append([]string(nil), values...)
+	}
+
+	return clone
+}
+
+// recordingWriter keeps a copy of the body of the response while it is sent to the client
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,191 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newRouter(store *Store, handler gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
+	r.POST("/pet", Idempotent(store, handler))
+	r.POST("/other", Idempotent(store, handler))
+
+	return r
+}
+
+func post(r *gin.Engine, path string, apiKey string, key string, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("api_key", apiKey)
+	if key != "" {
+		req.Header.Set(HeaderName, key)
+	}
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+// counter replies with the number of times it has been called
+func counter(calls *int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		*calls++
+		c.Header("Location", "/pet/"+strconv.Itoa(*calls))
+		c.JSON(http.StatusOK, gin.H{"id": *calls})
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	calls := 0
+	store := NewStore(time.Hour)
+	r := newRouter(store, counter(&calls))
+
+	first := post(r, "/pet", "a", "key-1", `{"name":"rex"}`)
+	require.Equal(t, http.StatusOK, first.Code)
+	require.Equal(t, `{"id":1}`, first.Body.String())
+	require.Empty(t, first.Header().Get(ReplayedHeaderName))
+
+	// the retries get the first response without running the handler
+	replayed := post(r, "/pet", "a", "key-1", `{"name":"rex"}`)
+	require.Equal(t, http.StatusOK, replayed.Code)
+	require.Equal(t, `{"id":1}`, replayed.Body.String())
+	require.Equal(t, "/pet/1", replayed.Header().Get("Location"))
+	require.Equal(t, "application/json; charset=utf-8", replayed.Header().Get("Content-Type"))
+	require.Equal(t, "true", replayed.Header().Get(ReplayedHeaderName))
+	require.Equal(t, 1, calls)
+
+	// the keys are scoped by API key, and the requests without a key are always handled
+	require.Equal(t, `{"id":2}`, post(r, "/pet", "b", "key-1", `{"name":"rex"}`).Body.String())
+	require.Equal(t, `{"id":3}`, post(r, "/pet", "a", "", `{"name":"rex"}`).Body.String())
+	require.Equal(t, `{"id":4}`, post(r, "/pet", "a", "", `{"name":"rex"}`).Body.String())
+
+	// the store keeps a hash of the API keys, not the keys
+	require.Contains(t, store.entries, ownerOf("a")+"\x00key-1")
+	require.NotContains(t, store.entries, "a\x00key-1")
+
+	// the anonymous callers cannot be told apart, they cannot send a key
+	anonymous := post(r, "/pet", "", "key-1", `{"name":"rex"}`)
+	require.Equal(t, http.StatusBadRequest, anonymous.Code)
+	require.Equal(t, `{"code":400,"type":"error","message":"Invalid input","errors":[{"field":"api_key","code":"required","message":"is required with an Idempotency-Key"}]}`,
+		anonymous.Body.String())
+
+	// the same key cannot be used for another request
+	for path, body := range map[string]string{
+		"/pet":                  `{"name":"max"}`,
+		"/other":                `{"name":"rex"}`,
+		"/pet?mode=best-effort": `{"name":"rex"}`,
+	} {
+		recorder := post(r, path, "a", "key-1", body)
+		require.Equal(t, http.StatusUnprocessableEntity, recorder.Code, path)
+		require.Equal(t, `{"code":422,"type":"error","message":"Idempotency-Key has already been used with another request"}`,
+			recorder.Body.String(), path)
+	}
+	require.Equal(t, 4, calls)
+
+	// the query is part of the request, another mode of a bulk request is another request
+	require.Equal(t, http.StatusOK, post(r, "/pet?mode=atomic", "a", "key-2", `[]`).Code)
+	require.Equal(t, http.StatusUnprocessableEntity, post(r, "/pet?mode=best-effort", "a", "key-2", `[]`).Code)
+	require.Equal(t, 5, calls)
+
+	recorder := post(r, "/pet", "a", strings.Repeat("k", 256), `{}`)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestIdempotentInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	r := newRouter(NewStore(time.Hour), func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(http.StatusOK, gin.H{"id": 1})
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- post(r, "/pet", "a", "key-1", `{}`)
+	}()
+	<-started
+
+	// the duplicates are turned away until the first request is done
+	recorder := post(r, "/pet", "a", "key-1", `{}`)
+	require.Equal(t, http.StatusConflict, recorder.Code)
+	require.Equal(t, "1", recorder.Header().Get("Retry-After"))
+
+	close(release)
+	require.Equal(t, http.StatusOK, (<-done).Code)
+
+	recorder = post(r, "/pet", "a", "key-1", `{}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "true", recorder.Header().Get(ReplayedHeaderName))
+}
+
+func TestIdempotentExpiryAndFailures(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	store := NewStore(time.Minute)
+	store.now = func() time.Time { return now }
+
+	calls := 0
+	failing := true
+	r := newRouter(store, func(c *gin.Context) {
+		calls++
+		if failing {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": calls})
+	})
+
+	// the failed requests are not stored, the clients can retry them
+	require.Equal(t, http.StatusInternalServerError, post(r, "/pet", "a", "key-1", `{}`).Code)
+	failing = false
+	require.Equal(t, `{"id":2}`, post(r, "/pet", "a", "key-1", `{}`).Body.String())
+
+	now = now.Add(59 * time.Second)
+	require.Equal(t, `{"id":2}`, post(r, "/pet", "a", "key-1", `{}`).Body.String())
+
+	// the responses are forgotten after the ttl
+	now = now.Add(time.Second)
+	require.Equal(t, `{"id":3}`, post(r, "/pet", "a", "key-1", `{}`).Body.String())
+	require.Len(t, store.entries, 1)
+
+	// the other keys stay in the store until they are swept
+	require.Equal(t, `{"id":4}`, post(r, "/pet", "a", "key-2", `{}`).Body.String())
+	now = now.Add(time.Minute)
+	require.Len(t, store.entries, 2)
+
+	store.sweep()
+	require.Empty(t, store.entries)
+}
+
+func TestStoreSweeps(t *testing.T) {
+	store := NewStore(time.Minute)
+	store.StartSweeps(time.Millisecond)
+	defer store.Close()
+
+	store.begin("key-1", "fingerprint")
+	store.finish("key-1", &response{status: http.StatusOK})
+	store.mu.Lock()
+	store.entries["key-1"].expires = time.Now().Add(-time.Second)
+	store.mu.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mu.Lock()
+		remaining := len(store.entries)
+		store.mu.Unlock()
+
+		if remaining == 0 {
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "the expired response was not swept")
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,45 @@
+package models
+
+// The statuses of the items of a bulk request
+const (
+	BulkCreated = "created"
+	BulkUpdated = "updated"
+	BulkDeleted = "deleted"
+	// BulkFailed is the status of the items that are invalid or could not be saved
+	BulkFailed = "error"
+	// BulkSkipped is the status of the valid items of an atomic request that failed because of another item
+	BulkSkipped = "skipped"
+)
+
+// BulkResult is the outcome of an item of a bulk request
+type BulkResult struct {
+	// Index is the position of the item in the request
+	Index  int    `json:"index"`
+	ID     uint64 `json:"id,omitempty"`
+	Status string `json:"status"`
+	// Message tells why the item failed or was skipped
+	Message string           `json:"message,omitempty"`
+	Errors  ValidationErrors `json:"errors,omitempty"`
+}
+
+// BulkResponse is the response of a bulk request, there is a result per item in the order of the request
+type BulkResponse struct {
+	Atomic    bool         `json:"atomic"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
+
+// NewBulkResponse will count the items that succeeded and the ones that failed, the skipped items count as failed
+func NewBulkResponse(atomic bool, results []BulkResult) BulkResponse {
+	response := BulkResponse{Atomic: atomic, Results: results}
+	for _, result := range results {
+		if result.Status == BulkFailed || result.Status == BulkSkipped {
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+	}
+
+	return response
+}
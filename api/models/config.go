@@ -2,12 +2,32 @@ package models
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
-// Config is the configuration for the database
+// defaultMaintenanceDbName is the database that always exists on a PostgreSQL server
+// and that we connect to in order to create the application database
+const defaultMaintenanceDbName = "postgres"
+
+// storageDrivers are the places where the uploaded files can be kept
+var storageDrivers = map[string]bool{
+	"local": true,
+	"s3":    true,
+}
+
+// notifiers are the ways the owners of the saved searches can be notified
+var notifiers = map[string]bool{
+	"log":  true,
+	"smtp": true,
+}
+
+// Config is the configuration of the application
 type Config struct {
 	DbUser     string
 	DbPassword string
@@ -15,6 +35,72 @@ type Config struct {
 	DbHost     string
 	DbName     string
 	DbDriver   string
+
+	// DbMaintenanceName is the database used to check if DbName exists and to create it
+	DbMaintenanceName string
+	// DbCreate allows the application to create DbName when it does not exist yet
+	DbCreate bool
+
+	// DbConnectRetries is the number of times the connection is retried on startup before giving up
+	DbConnectRetries int
+	// DbRetryBackoff is the wait before the first retry, it doubles after every failed attempt
+	DbRetryBackoff time.Duration
+	// DbRetryMaxBackoff caps the wait between two attempts
+	DbRetryMaxBackoff time.Duration
+
+	// DbMaxOpenConns is the maximum number of open connections in the pool, 0 means unlimited
+	DbMaxOpenConns int
+	// DbMaxIdleConns is the maximum number of idle connections kept in the pool
+	DbMaxIdleConns int
+	// DbConnMaxLifetime is the maximum amount of time a connection may be reused, 0 means forever
+	DbConnMaxLifetime time.Duration
+
+	// DbReplicaURLs are the connection strings of the read replicas, the read queries are sent to them
+	DbReplicaURLs []string
+	// DbReplicaHealthCheckInterval is how often the replicas are pinged to know if they can be used
+	DbReplicaHealthCheckInterval time.Duration
+
+	// Notifier is how the owners of the saved searches are notified, either log or smtp
+	Notifier     string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// SMTPFrom is the address the notifications are sent from
+	SMTPFrom string
+
+	// PetCacheMaxAge is how long the clients can use a pet fetched by id without revalidating it, 0 means never
+	PetCacheMaxAge time.Duration
+	// FindByStatusCacheMaxAge is how long the clients can use the pets found by status without revalidating them
+	FindByStatusCacheMaxAge time.Duration
+
+	// IdempotencyKeyTTL is how long the responses of the requests with an Idempotency-Key are kept to be replayed
+	IdempotencyKeyTTL time.Duration
+
+	// StorageDriver is where the uploaded images are kept, either local or s3
+	StorageDriver string
+	// StorageLocalDir is the directory of the local storage
+	StorageLocalDir string
+	// S3Endpoint is the URL of the S3 compatible service of the s3 storage, e.g https://s3.eu-west-1.amazonaws.com
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+
+	// MaxUploadSize is the maximum size of an uploaded image in bytes
+	MaxUploadSize int
+	// MaxImageDimension is the maximum width and height of an uploaded image in pixels
+	MaxImageDimension int
+	// MaxImagePixels is the maximum number of pixels of an uploaded image, it rejects the images that are small
+	// files but huge once decoded
+	MaxImagePixels int
+
+	// PublicURL is the URL the clients use to reach the API, the URLs of the uploaded images start with it
+	PublicURL string
+
+	// StrictValidation checks the responses against the OpenAPI document too, it is meant for the tests
+	StrictValidation bool
 }
 
 // Validate will validate the config and make sure that all the env variables needed to establish the connection
@@ -43,12 +129,81 @@ func (c *Config) Validate() error {
 	if c.DbDriver == "" {
 		return fmt.Errorf("DbDriver is empty")
 	}
+
+	if c.DbMaintenanceName == "" {
+		return fmt.Errorf("DbMaintenanceName is empty")
+	}
+
+	if c.DbConnectRetries < 0 {
+		return fmt.Errorf("DbConnectRetries is negative")
+	}
+
+	if c.DbRetryBackoff < 0 || c.DbRetryMaxBackoff < 0 {
+		return fmt.Errorf("DbRetryBackoff and DbRetryMaxBackoff cannot be negative")
+	}
+
+	if c.DbMaxOpenConns < 0 || c.DbMaxIdleConns < 0 {
+		return fmt.Errorf("DbMaxOpenConns and DbMaxIdleConns cannot be negative")
+	}
+
+	if c.DbConnMaxLifetime < 0 {
+		return fmt.Errorf("DbConnMaxLifetime is negative")
+	}
+
+	if len(c.DbReplicaURLs) > 0 && c.DbReplicaHealthCheckInterval <= 0 {
+		return fmt.Errorf("DbReplicaHealthCheckInterval must be positive when replicas are configured")
+	}
+
+	if c.PetCacheMaxAge < 0 || c.FindByStatusCacheMaxAge < 0 {
+		return fmt.Errorf("PetCacheMaxAge and FindByStatusCacheMaxAge cannot be negative")
+	}
+
+	if c.IdempotencyKeyTTL <= 0 {
+		return fmt.Errorf("IdempotencyKeyTTL must be positive")
+	}
+
+	if !storageDrivers[c.StorageDriver] {
+		return fmt.Errorf("StorageDriver %q is not supported", c.StorageDriver)
+	}
+
+	if c.StorageDriver == "local" && c.StorageLocalDir == "" {
+		return fmt.Errorf("StorageLocalDir is required by the local storage")
+	}
+
+	if c.StorageDriver == "s3" && (c.S3Endpoint == "" || c.S3Bucket == "" || c.S3AccessKeyID == "" || c.S3SecretAccessKey == "") {
+		return fmt.Errorf("S3Endpoint, S3Bucket, S3AccessKeyID and S3SecretAccessKey are required by the s3 storage")
+	}
+
+	if c.MaxUploadSize <= 0 || c.MaxImageDimension <= 0 || c.MaxImagePixels <= 0 {
+		return fmt.Errorf("MaxUploadSize, MaxImageDimension and MaxImagePixels must be positive")
+	}
+
+	publicURL, err := url.Parse(c.PublicURL)
+	if err != nil || (publicURL.Scheme != "http" && publicURL.Scheme != "https") || publicURL.Host == "" {
+		return fmt.Errorf("PublicURL must be an absolute http or https URL")
+	}
+
+	if !notifiers[c.Notifier] {
+		return fmt.Errorf("Notifier %q is not supported", c.Notifier)
+	}
+
+	if c.Notifier == "smtp" && (c.SMTPHost == "" || c.SMTPFrom == "") {
+		return fmt.Errorf("SMTPHost and SMTPFrom are required by the smtp notifier")
+	}
 	return nil
 }
 
 func (c *Config) getDBConnectionURL() string {
+	return c.getConnectionURL(c.DbName)
+}
+
+func (c *Config) getMaintenanceConnectionURL() string {
+	return c.getConnectionURL(c.DbMaintenanceName)
+}
+
+func (c *Config) getConnectionURL(dbName string) string {
 	return fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s",
-		c.DbHost, c.DbPort, c.DbUser, c.DbName, c.DbPassword)
+		c.DbHost, c.DbPort, c.DbUser, dbName, c.DbPassword)
 }
 
 // NewConfig will return a new config
@@ -60,16 +215,225 @@ func NewConfig() (Config, error) {
 	DbName := os.Getenv("DB_NAME")
 	DbDriver := os.Getenv("DB_DRIVER")
 
+	DbMaintenanceName := os.Getenv("DB_MAINTENANCE_NAME")
+	if DbMaintenanceName == "" {
+		DbMaintenanceName = defaultMaintenanceDbName
+	}
+
+	DbCreate, err := getEnvBool("DB_CREATE", true)
+	if err != nil {
+		return Config{}, err
+	}
+
+	DbConnectRetries, err := getEnvInt("DB_CONNECT_RETRIES", 5)
+	if err != nil {
+		return Config{}, err
+	}
+
+	DbRetryBackoff, err := getEnvDuration("DB_RETRY_BACKOFF", time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+
+	DbRetryMaxBackoff, err := getEnvDuration("DB_RETRY_MAX_BACKOFF", 30*time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+
+	DbMaxOpenConns, err := getEnvInt("DB_MAX_OPEN_CONNS", 0)
+	if err != nil {
+		return Config{}, err
+	}
+
+	DbMaxIdleConns, err := getEnvInt("DB_MAX_IDLE_CONNS", 2)
+	if err != nil {
+		return Config{}, err
+	}
+
+	DbConnMaxLifetime, err := getEnvDuration("DB_CONN_MAX_LIFETIME", 0)
+	if err != nil {
+		return Config{}, err
+	}
+
+	DbReplicaHealthCheckInterval, err := getEnvDuration("DB_REPLICA_HEALTH_CHECK_INTERVAL", 10*time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+
+	PetCacheMaxAge, err := getEnvDuration("PET_CACHE_MAX_AGE", 0)
+	if err != nil {
+		return Config{}, err
+	}
+
+	FindByStatusCacheMaxAge, err := getEnvDuration("FIND_BY_STATUS_CACHE_MAX_AGE", 0)
+	if err != nil {
+		return Config{}, err
+	}
+
+	IdempotencyKeyTTL, err := getEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+
+	MaxUploadSize, err := getEnvInt("MAX_UPLOAD_SIZE", 10<<20)
+	if err != nil {
+		return Config{}, err
+	}
+
+	MaxImageDimension, err := getEnvInt("MAX_IMAGE_DIMENSION", 10000)
+	if err != nil {
+		return Config{}, err
+	}
+
+	MaxImagePixels, err := getEnvInt("MAX_IMAGE_PIXELS", 40000000)
+	if err != nil {
+		return Config{}, err
+	}
+
+	StrictValidation, err := getEnvBool("STRICT_VALIDATION", false)
+	if err != nil {
+		return Config{}, err
+	}
+
+	Notifier := os.Getenv("NOTIFIER")
+	if Notifier == "" {
+		Notifier = "log"
+	}
+
+	SMTPPort := os.Getenv("SMTP_PORT")
+	if SMTPPort == "" {
+		SMTPPort = "25"
+	}
+
+	StorageDriver := os.Getenv("STORAGE_DRIVER")
+	if StorageDriver == "" {
+		StorageDriver = "local"
+	}
+
+	StorageLocalDir := os.Getenv("STORAGE_LOCAL_DIR")
+	if StorageLocalDir == "" {
+		StorageLocalDir = "uploads"
+	}
+
+	S3Region := os.Getenv("S3_REGION")
+	if S3Region == "" {
+		S3Region = "us-east-1"
+	}
+
+	PublicURL := os.Getenv("PUBLIC_URL")
+	if PublicURL == "" {
+		PublicURL = "http://localhost:8080"
+	}
+
 	return Config{
-		DbUser,
-		DbPassword,
-		DbPort,
-		DbHost,
-		DbName,
-		DbDriver,
+		DbUser:            DbUser,
+		DbPassword:        DbPassword,
+		DbPort:            DbPort,
+		DbHost:            DbHost,
+		DbName:            DbName,
+		DbDriver:          DbDriver,
+		DbMaintenanceName: DbMaintenanceName,
+		DbCreate:          DbCreate,
+		DbConnectRetries:  DbConnectRetries,
+		DbRetryBackoff:    DbRetryBackoff,
+		DbRetryMaxBackoff: DbRetryMaxBackoff,
+		DbMaxOpenConns:    DbMaxOpenConns,
+		DbMaxIdleConns:    DbMaxIdleConns,
+		DbConnMaxLifetime: DbConnMaxLifetime,
+
+		DbReplicaURLs:                getEnvList("DB_REPLICA_URLS"),
+		DbReplicaHealthCheckInterval: DbReplicaHealthCheckInterval,
+
+		Notifier:     Notifier,
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     SMTPPort,
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:     os.Getenv("SMTP_FROM"),
+
+		PetCacheMaxAge:          PetCacheMaxAge,
+		FindByStatusCacheMaxAge: FindByStatusCacheMaxAge,
+
+		IdempotencyKeyTTL: IdempotencyKeyTTL,
+
+		StorageDriver:     StorageDriver,
+		StorageLocalDir:   StorageLocalDir,
+		S3Endpoint:        os.Getenv("S3_ENDPOINT"),
+		S3Region:          S3Region,
+		S3Bucket:          os.Getenv("S3_BUCKET"),
+		S3AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+
+		MaxUploadSize:     MaxUploadSize,
+		MaxImageDimension: MaxImageDimension,
+		MaxImagePixels:    MaxImagePixels,
+
+		PublicURL: strings.TrimSuffix(PublicURL, "/"),
+
+		StrictValidation: StrictValidation,
 	}, nil
 }
 
+// getEnvBool will read a boolean from the environment and fall back to the default value when it is not set
+func getEnvBool(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s is not a valid boolean: %q", key, value)
+	}
+
+	return parsed, nil
+}
+
+// getEnvInt will read an integer from the environment and fall back to the default value when it is not set
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a valid integer: %q", key, value)
+	}
+
+	return parsed, nil
+}
+
+// getEnvDuration will read a duration (e.g 500ms, 2s) from the environment
+// and fall back to the default value when it is not set
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a valid duration: %q", key, value)
+	}
+
+	return parsed, nil
+}
+
+// getEnvList will read a comma separated list from the environment, empty values are ignored
+func getEnvList(key string) []string {
+	var values []string
+
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
 // LoadEnvFile should load the .env file
 func LoadEnvFile() error {
 	err := godotenv.Load()
@@ -3,6 +3,7 @@ package models
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestNewConfigValidation(t *testing.T) {
@@ -33,3 +34,254 @@ func TestNewConfigValidation(t *testing.T) {
 		t.Fatal("config should be valid")
 	}
 }
+
+func TestNewConfigDatabaseBootstrap(t *testing.T) {
+	os.Setenv("DB_MAINTENANCE_NAME", "")
+	os.Setenv("DB_CREATE", "")
+
+	c, err := NewConfig()
+	if err != nil {
+		t.Fatal("there should be no errors creating the config")
+	}
+
+	if c.DbMaintenanceName != "postgres" || !c.DbCreate {
+		t.Fatalf("unexpected defaults: %+v", c)
+	}
+
+	os.Setenv("DB_CREATE", "not-a-bool")
+	_, err = NewConfig()
+	if err == nil {
+		t.Fatal("an invalid DB_CREATE value should be rejected")
+	}
+
+	os.Setenv("DB_CREATE", "false")
+	os.Setenv("DB_MAINTENANCE_NAME", "template1")
+	c, err = NewConfig()
+	if err != nil {
+		t.Fatal("there should be no errors creating the config")
+	}
+
+	if c.DbMaintenanceName != "template1" || c.DbCreate {
+		t.Fatalf("unexpected values: %+v", c)
+	}
+
+	os.Unsetenv("DB_CREATE")
+	os.Unsetenv("DB_MAINTENANCE_NAME")
+}
+
+func TestNewConfigConnectionPool(t *testing.T) {
+	os.Setenv("DB_CONNECT_RETRIES", "3")
+	os.Setenv("DB_RETRY_BACKOFF", "250ms")
+	os.Setenv("DB_MAX_OPEN_CONNS", "20")
+	os.Setenv("DB_CONN_MAX_LIFETIME", "5m")
+
+	c, err := NewConfig()
+	if err != nil {
+		t.Fatal("there should be no errors creating the config")
+	}
+
+	if c.DbConnectRetries != 3 || c.DbRetryBackoff != 250*time.Millisecond || c.DbRetryMaxBackoff != 30*time.Second {
+		t.Fatalf("unexpected retry values: %+v", c)
+	}
+
+	if c.DbMaxOpenConns != 20 || c.DbMaxIdleConns != 2 || c.DbConnMaxLifetime != 5*time.Minute {
+		t.Fatalf("unexpected pool values: %+v", c)
+	}
+
+	os.Setenv("DB_RETRY_BACKOFF", "soon")
+	_, err = NewConfig()
+	if err == nil {
+		t.Fatal("an invalid DB_RETRY_BACKOFF value should be rejected")
+	}
+
+	os.Setenv("DB_RETRY_BACKOFF", "")
+	os.Setenv("DB_MAX_OPEN_CONNS", "many")
+	_, err = NewConfig()
+	if err == nil {
+		t.Fatal("an invalid DB_MAX_OPEN_CONNS value should be rejected")
+	}
+
+	os.Setenv("DB_MAX_OPEN_CONNS", "-1")
+	c, err = NewConfig()
+	if err != nil {
+		t.Fatal("there should be no errors creating the config")
+	}
+
+	err = c.Validate()
+	if err == nil {
+		t.Fatal("a negative pool size should be invalid")
+	}
+
+	os.Unsetenv("DB_CONNECT_RETRIES")
+	os.Unsetenv("DB_RETRY_BACKOFF")
+	os.Unsetenv("DB_MAX_OPEN_CONNS")
+	os.Unsetenv("DB_CONN_MAX_LIFETIME")
+}
+
+func TestNewConfigReplicas(t *testing.T) {
+	os.Setenv("DB_REPLICA_URLS", "host=replica1 port=5432, ,host=replica2 port=5432")
+
+	c, err := NewConfig()
+	if err != nil {
+		t.Fatal("there should be no errors creating the config")
+	}
+
+	if len(c.DbReplicaURLs) != 2 || c.DbReplicaURLs[0] != "host=replica1 port=5432" {
+		t.Fatalf("unexpected replicas: %q", c.DbReplicaURLs)
+	}
+
+	if c.DbReplicaHealthCheckInterval != 10*time.Second {
+		t.Fatalf("unexpected health check interval: %s", c.DbReplicaHealthCheckInterval)
+	}
+
+	os.Unsetenv("DB_REPLICA_URLS")
+}
+
+func TestNewConfigNotifier(t *testing.T) {
+	os.Setenv("DB_USER", "test1")
+	os.Setenv("DB_PASSWORD", "test2")
+	os.Setenv("DB_PORT", "test3")
+	os.Setenv("DB_HOST", "test4")
+	os.Setenv("DB_NAME", "test5")
+	os.Setenv("DB_DRIVER", "test6")
+
+	c, err := NewConfig()
+	if err != nil {
+		t.Fatal("there should be no errors creating the config")
+	}
+
+	if c.Notifier != "log" || c.SMTPPort != "25" {
+		t.Fatalf("unexpected defaults: %+v", c)
+	}
+
+	os.Setenv("NOTIFIER", "pigeon")
+	c, _ = NewConfig()
+	if c.Validate() == nil {
+		t.Fatal("an unknown notifier should be invalid")
+	}
+
+	os.Setenv("NOTIFIER", "smtp")
+	c, _ = NewConfig()
+	if c.Validate() == nil {
+		t.Fatal("the smtp notifier should require a host and a sender")
+	}
+
+	os.Setenv("SMTP_HOST", "localhost")
+	os.Setenv("SMTP_FROM", "petstore@example.com")
+	c, _ = NewConfig()
+	if err = c.Validate(); err != nil {
+		t.Fatalf("config should be valid: %v", err)
+	}
+
+	os.Unsetenv("NOTIFIER")
+	os.Unsetenv("SMTP_HOST")
+	os.Unsetenv("SMTP_FROM")
+}
+
+func TestNewConfigCache(t *testing.T) {
+	os.Setenv("PET_CACHE_MAX_AGE", "30s")
+
+	c, err := NewConfig()
+	if err != nil {
+		t.Fatal("there should be no errors creating the config")
+	}
+
+	if c.PetCacheMaxAge != 30*time.Second || c.FindByStatusCacheMaxAge != 0 {
+		t.Fatalf("unexpected cache max ages: %+v", c)
+	}
+
+	os.Setenv("FIND_BY_STATUS_CACHE_MAX_AGE", "-1s")
+	c, err = NewConfig()
+	if err != nil {
+		t.Fatal("there should be no errors creating the config")
+	}
+
+	if c.Validate() == nil {
+		t.Fatal("a negative max age should be invalid")
+	}
+
+	os.Unsetenv("PET_CACHE_MAX_AGE")
+	os.Unsetenv("FIND_BY_STATUS_CACHE_MAX_AGE")
+}
+
+func TestNewConfigIdempotencyKeyTTL(t *testing.T) {
+	c, err := NewConfig()
+	if err != nil {
+		t.Fatal("there should be no errors creating the config")
+	}
+
+	if c.IdempotencyKeyTTL != 24*time.Hour {
+		t.Fatalf("unexpected idempotency key ttl: %v", c.IdempotencyKeyTTL)
+	}
+
+	os.Setenv("IDEMPOTENCY_KEY_TTL", "0s")
+	c, err = NewConfig()
+	if err != nil {
+		t.Fatal("there should be no errors creating the config")
+	}
+
+	if c.Validate() == nil {
+		t.Fatal("a ttl of 0 should be invalid")
+	}
+
+	os.Unsetenv("IDEMPOTENCY_KEY_TTL")
+}
+
+func TestNewConfigStorage(t *testing.T) {
+	os.Setenv("DB_USER", "test1")
+	os.Setenv("DB_PASSWORD", "test2")
+	os.Setenv("DB_PORT", "test3")
+	os.Setenv("DB_HOST", "test4")
+	os.Setenv("DB_NAME", "test5")
+	os.Setenv("DB_DRIVER", "test6")
+
+	c, err := NewConfig()
+	if err != nil {
+		t.Fatal("there should be no errors creating the config")
+	}
+
+	if c.StorageDriver != "local" || c.StorageLocalDir != "uploads" || c.S3Region != "us-east-1" || c.PublicURL != "http://localhost:8080" {
+		t.Fatalf("unexpected defaults: %+v", c)
+	}
+
+	if c.MaxUploadSize != 10<<20 || c.MaxImageDimension != 10000 || c.MaxImagePixels != 40000000 {
+		t.Fatalf("unexpected upload limits: %+v", c)
+	}
+
+	os.Setenv("MAX_UPLOAD_SIZE", "0")
+	c, _ = NewConfig()
+	if c.Validate() == nil {
+		t.Fatal("an upload size of 0 should be invalid")
+	}
+	os.Unsetenv("MAX_UPLOAD_SIZE")
+
+	os.Setenv("STORAGE_DRIVER", "s3")
+	c, _ = NewConfig()
+	if c.Validate() == nil {
+		t.Fatal("the s3 storage should require an endpoint, a bucket and credentials")
+	}
+
+	os.Setenv("S3_ENDPOINT", "http://localhost:9000")
+	os.Setenv("S3_BUCKET", "pets")
+	os.Setenv("S3_ACCESS_KEY_ID", "minio")
+	os.Setenv("S3_SECRET_ACCESS_KEY", "minio123")
+	os.Setenv("PUBLIC_URL", "https://petstore.example.com/")
+	c, _ = NewConfig()
+	if err = c.Validate(); err != nil {
+		t.Fatalf("config should be valid: %v", err)
+	}
+
+	if c.PublicURL != "https://petstore.example.com" {
+		t.Fatalf("unexpected public url: %q", c.PublicURL)
+	}
+
+	os.Setenv("PUBLIC_URL", "petstore.example.com")
+	c, _ = NewConfig()
+	if c.Validate() == nil {
+		t.Fatal("a relative public url should be invalid")
+	}
+
+	for _, key := range []string{"STORAGE_DRIVER", "S3_ENDPOINT", "S3_BUCKET", "S3_ACCESS_KEY_ID", "S3_SECRET_ACCESS_KEY", "PUBLIC_URL"} {
+		os.Unsetenv(key)
+	}
+}
@@ -1,23 +1,118 @@
 package models
 
 import (
-	"bytes"
+	"database/sql"
+	"fmt"
 	"log"
-	"os/exec"
+	"time"
 
 	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
 )
 
-// CreatePgDb will create a new db based on the environment variables
-func CreatePgDb(c Config) error {
-	cmd := exec.Command("createdb", "-p", "5432", "-h", c.DbHost, "-U", c.DbUser, "-e", c.DbName)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	if err := cmd.Run(); err != nil {
-		log.Printf("Error: %v", err)
+// sleep is replaced in the tests so that the retries do not slow them down
+var sleep = time.Sleep
+
+// ConnectWithRetry will bootstrap the database and open a connection to it.
+// Failed attempts are retried with an exponential backoff, this gives the database time to start
+// when the application and the database are started at the same time (e.g docker-compose)
+func ConnectWithRetry(c Config) (*gorm.DB, error) {
+	var db *gorm.DB
+
+	err := retryWithBackoff(c.DbConnectRetries, c.DbRetryBackoff, c.DbRetryMaxBackoff, func() error {
+		err := EnsurePgDb(c)
+		if err != nil {
+			return err
+		}
+
+		db, err = OpenAndTestDBConnection(c)
 		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// retryWithBackoff will call the operation until it succeeds or until it has been retried too many times
+func retryWithBackoff(retries int, backoff time.Duration, maxBackoff time.Duration, operation func() error) error {
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		err = operation()
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= retries {
+			return fmt.Errorf("giving up after %d attempts: %v", attempt+1, err)
+		}
+
+		wait := backoffDuration(attempt, backoff, maxBackoff)
+		log.Printf("attempt %d failed: %v, retrying in %s", attempt+1, err, wait)
+		sleep(wait)
+	}
+}
+
+// backoffDuration will return how long to wait before the next attempt, the wait doubles after every attempt
+func backoffDuration(attempt int, backoff time.Duration, maxBackoff time.Duration) time.Duration {
+	wait := backoff
+	for i := 0; i < attempt; i++ {
+		wait *= 2
+		if maxBackoff > 0 && wait >= maxBackoff {
+			return maxBackoff
+		}
+	}
+
+	if maxBackoff > 0 && wait > maxBackoff {
+		return maxBackoff
+	}
+
+	return wait
+}
+
+// EnsurePgDb will connect to the maintenance database and create the application database if it does not exist yet
+func EnsurePgDb(c Config) error {
+	db, err := sql.Open(c.DbDriver, c.getMaintenanceConnectionURL())
+	if err != nil {
+		return fmt.Errorf("could not open a connection to the maintenance database %q: %v", c.DbMaintenanceName, err)
+	}
+	defer db.Close()
+
+	err = db.Ping()
+	if err != nil {
+		return fmt.Errorf("could not connect to the maintenance database %q on %s:%s: %v",
+			c.DbMaintenanceName, c.DbHost, c.DbPort, err)
 	}
-	log.Printf("Output: %q\n", out.String())
+
+	return createDatabaseIfMissing(db, c.DbName, c.DbCreate)
+}
+
+// createDatabaseIfMissing will check if the database exists and create it when allowed to
+func createDatabaseIfMissing(db *sql.DB, name string, create bool) error {
+	var exists int
+	err := db.QueryRow("SELECT 1 FROM pg_database WHERE datname = $1", name).Scan(&exists)
+	if err == nil {
+		log.Printf("database %q already exists", name)
+		return nil
+	}
+
+	if err != sql.ErrNoRows {
+		return fmt.Errorf("could not check if the database %q exists: %v", name, err)
+	}
+
+	if !create {
+		return fmt.Errorf("database %q does not exist and DB_CREATE is disabled", name)
+	}
+
+	// CREATE DATABASE does not accept placeholders so the name has to be quoted as an identifier
+	_, err = db.Exec("CREATE DATABASE " + pq.QuoteIdentifier(name))
+	if err != nil {
+		return fmt.Errorf("could not create the database %q: %v", name, err)
+	}
+
+	log.Printf("database %q created", name)
 	return nil
 }
 
@@ -29,8 +124,19 @@ func OpenAndTestDBConnection(c Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// configure the connection pool
+	DB.DB().SetMaxOpenConns(c.DbMaxOpenConns)
+	DB.DB().SetMaxIdleConns(c.DbMaxIdleConns)
+	DB.DB().SetConnMaxLifetime(c.DbConnMaxLifetime)
+
 	DB.CreateTable()
-	DB.Debug().AutoMigrate(&Pet{}, &Category{}, &Tag{})
+	DB.Debug().AutoMigrate(&Pet{}, &Category{}, &Tag{}, &SavedSearch{}, &SavedSearchNotification{}, &PetImage{}, &DataMigration{})
+
+	err = RunDataMigrations(DB)
+	if err != nil {
+		DB.Close()
+		return nil, err
+	}
 
 	return DB, nil
 }
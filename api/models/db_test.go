@@ -0,0 +1,96 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateDatabaseIfMissing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	existsQuery := regexp.QuoteMeta(`SELECT 1 FROM pg_database WHERE datname = $1`)
+
+	// the database already exists
+	mock.ExpectQuery(existsQuery).
+		WithArgs("petstore").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+
+	err = createDatabaseIfMissing(db, "petstore", true)
+	require.NoError(t, err)
+
+	// the database is missing and can be created
+	mock.ExpectQuery(existsQuery).
+		WithArgs("petstore").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE DATABASE "petstore"`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = createDatabaseIfMissing(db, "petstore", true)
+	require.NoError(t, err)
+
+	// the database is missing and cannot be created
+	mock.ExpectQuery(existsQuery).
+		WithArgs("petstore").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+
+	err = createDatabaseIfMissing(db, "petstore", false)
+	require.EqualError(t, err, `database "petstore" does not exist and DB_CREATE is disabled`)
+
+	// the creation fails
+	mock.ExpectQuery(existsQuery).
+		WithArgs("petstore").
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE DATABASE "petstore"`)).
+		WillReturnError(fmt.Errorf("permission denied to create database"))
+
+	err = createDatabaseIfMissing(db, "petstore", true)
+	require.EqualError(t, err, `could not create the database "petstore": permission denied to create database`)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackoffDuration(t *testing.T) {
+	require.Equal(t, time.Second, backoffDuration(0, time.Second, 10*time.Second))
+	require.Equal(t, 2*time.Second, backoffDuration(1, time.Second, 10*time.Second))
+	require.Equal(t, 8*time.Second, backoffDuration(3, time.Second, 10*time.Second))
+	require.Equal(t, 10*time.Second, backoffDuration(4, time.Second, 10*time.Second))
+	require.Equal(t, 10*time.Second, backoffDuration(100, time.Second, 10*time.Second))
+	require.Equal(t, 10*time.Second, backoffDuration(0, 20*time.Second, 10*time.Second))
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	defer func() { sleep = time.Sleep }()
+
+	// succeeds on the third attempt
+	attempts := 0
+	err := retryWithBackoff(5, time.Second, 3*time.Second, func() error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+
+	// never succeeds
+	waits = nil
+	attempts = 0
+	err = retryWithBackoff(2, time.Second, 3*time.Second, func() error {
+		attempts++
+		return fmt.Errorf("connection refused")
+	})
+	require.EqualError(t, err, "giving up after 3 attempts: connection refused")
+	require.Equal(t, 3, attempts)
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+}
@@ -1,9 +1,27 @@
 package models
 
-import "mime/multipart"
+import (
+	"mime/multipart"
+	"time"
+)
 
 // FileForm is the form that contains data about a file uploaded from a client
 type FileForm struct {
 	AdditionalMetadata string                `form:"additionalMetadata" binding:"required"`
 	File               *multipart.FileHeader `form:"file" binding:"required"`
 }
+
+// PetImage is an image of a pet uploaded by a client.
+// The content is kept by the storage under a generated key, the name sent by the client is only kept to be shown
+type PetImage struct {
+	ID          uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	PetID       uint64     `gorm:"not null;index" json:"petId"`
+	Key         string     `gorm:"size:255;not null" json:"-"`
+	Filename    string     `gorm:"size:255" json:"filename"`
+	ContentType string     `gorm:"size:100" json:"contentType"`
+	Size        int64      `json:"size"`
+	CreatedAt   *time.Time `json:"createdAt,omitempty"`
+
+	// URL is where the clients can download the image, it is added to the photo URLs of the pet
+	URL string `gorm:"-" json:"url"`
+}
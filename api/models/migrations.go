@@ -0,0 +1,181 @@
+package models
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// DataMigration records a one-off change that has been applied to the database.
+// The schema is kept up to date by AutoMigrate, the data migrations change the data and the columns it cannot alter
+type DataMigration struct {
+	Name      string `gorm:"primary_key;size:255"`
+	AppliedAt time.Time
+}
+
+// dataMigration is a one-off change of the data, it runs in the transaction that records it
+type dataMigration struct {
+	name string
+	run  func(tx *gorm.DB) error
+}
+
+// dataMigrations are applied in order, a migration must never be renamed or removed once released
+var dataMigrations = []dataMigration{
+	{name: "unescape_pet_html", run: unescapePetHTML},
+	{name: "widen_photos_urls", run: widenPhotosURLs},
+}
+
+// RunDataMigrations will apply the data migrations that have not been applied to the database yet
+func RunDataMigrations(db *gorm.DB) error {
+	for _, migration := range dataMigrations {
+		err := runDataMigration(db, migration)
+		if err != nil {
+			return fmt.Errorf("data migration %q failed: %v", migration.name, err)
+		}
+	}
+
+	return nil
+}
+
+// dataMigrationsLock is the key of the postgres advisory lock held while a data migration is checked and applied
+const dataMigrationsLock = 2019100101
+
+// runDataMigration will apply a migration and record it in a single transaction.
+// On postgres the transaction holds an advisory lock, an instance that starts at the same time waits for it
+// and then finds the migration applied instead of applying it twice
+func runDataMigration(db *gorm.DB, migration dataMigration) error {
+	tx := db.Debug().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if tx.Dialect().GetName() == "postgres" {
+		// the lock is released when the transaction ends
+		err := tx.Exec("SELECT pg_advisory_xact_lock(?)", dataMigrationsLock).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	var applied DataMigration
+	err := tx.Where("name = ?", migration.name).First(&applied).Error
+	if err == nil {
+		tx.Rollback()
+		return nil
+	}
+
+	if !gorm.IsRecordNotFoundError(err) {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Create(&DataMigration{Name: migration.name, AppliedAt: time.Now()}).Error
+	if err == nil {
+		err = migration.run(tx)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	log.Printf("data migration %q applied", migration.name)
+	return tx.Commit().Error
+}
+
+// unescapePetHTML turns back into raw text the values of the pets that used to be HTML escaped before being saved.
+// Only the values with an & can have been escaped, and the raw values saved by PUT /pet or the form are kept:
+// a value is only unescaped when it looks like escaped output, see unescaped
+func unescapePetHTML(tx *gorm.DB) error {
+	var pets []Pet
+	err := tx.Select("id, name, status, photos_urls").
+		Where("name LIKE ? OR status LIKE ? OR array_to_string(photos_urls, ' ') LIKE ?", "%&%", "%&%", "%&%").
+		Find(&pets).Error
+	if err != nil {
+		return err
+	}
+
+	for _, pet := range pets {
+		name := unescaped(pet.Name)
+		status := unescaped(pet.Status)
+		changed := name != pet.Name || status != pet.Status
+
+		photosURLs := make(pq.StringArray, len(pet.PhotosURLs))
+		for i, photoURL := range pet.PhotosURLs {
+			photosURLs[i] = unescaped(photoURL)
+			changed = changed || photosURLs[i] != photoURL
+		}
+
+		if !changed {
+			continue
+		}
+
+		// UpdateColumns keeps the updated_at of the pets, their values have not been changed by a user
+		err = tx.Model(&Pet{}).Where("id = ?", pet.ID).UpdateColumns(map[string]interface{}{
+			"name":        name,
+			"status":      status,
+			"photos_urls": photosURLs,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, table := range []string{"categories", "tags"} {
+		err = unescapeNames(tx, table)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unescaped will unescape a value that html.EscapeString could have written, the others are returned as they are.
+// A raw value like "R&D" is not escaped output, its & would have been written &amp;
+func unescaped(value string) string {
+	unescapedValue := html.UnescapeString(value)
+	if html.EscapeString(unescapedValue) != value {
+		return value
+	}
+
+	return unescapedValue
+}
+
+// widenPhotosURLs will widen the column of the photo URLs to its size in the Pet model,
+// AutoMigrate only creates the columns that are missing
+func widenPhotosURLs(tx *gorm.DB) error {
+	return tx.Model(&Pet{}).ModifyColumn("photos_urls", "varchar(2048)[]").Error
+}
+
+// unescapeNames will unescape the escaped names of the rows of a table, the categories or the tags
+func unescapeNames(tx *gorm.DB, table string) error {
+	var rows []struct {
+		ID   uint64
+		Name string
+	}
+
+	err := tx.Table(table).Select("id, name").Where("name LIKE ?", "%&%").Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		name := unescaped(row.Name)
+		if name == row.Name {
+			continue
+		}
+
+		err = tx.Table(table).Where("id = ?", row.ID).UpdateColumn("name", name).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
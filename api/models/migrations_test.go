@@ -0,0 +1,85 @@
+package models
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunDataMigrations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	require.NoError(t, err)
+
+	lockQuery := regexp.QuoteMeta(`SELECT pg_advisory_xact_lock($1)`)
+	appliedQuery := regexp.QuoteMeta(`SELECT * FROM "data_migrations" WHERE (name = $1) ORDER BY "data_migrations"."name" ASC LIMIT 1`)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(lockQuery).WithArgs(dataMigrationsLock).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(appliedQuery).
+		WithArgs("unescape_pet_html").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "applied_at"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "data_migrations" ("name","applied_at") VALUES ($1,$2) RETURNING "data_migrations"."name"`)).
+		WithArgs("unescape_pet_html", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("unescape_pet_html"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, name, status, photos_urls FROM "pets" `+
+		`WHERE (name LIKE $1 OR status LIKE $2 OR array_to_string(photos_urls, ' ') LIKE $3)`)).
+		WithArgs("%&%", "%&%", "%&%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status", "photos_urls"}).
+			AddRow(3, "Tom &amp; Jerry", "available", "{https://example.com/tom.png?size=1&amp;format=png}").
+			// the raw values saved by PUT /pet were never escaped, they are kept
+			AddRow(4, "R&D", "available", "{https://example.com/rd.png?size=1&format=png}").
+			AddRow(6, "Rock & Roll &amp; Blues", "available", "{}"))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "pets" SET "name" = $1, "photos_urls" = $2, "status" = $3 WHERE (id = $4)`)).
+		WithArgs("Tom & Jerry", pq.StringArray{"https://example.com/tom.png?size=1&format=png"}, "available", 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, name FROM "categories" WHERE (name LIKE $1)`)).
+		WithArgs("%&%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "&lt;cats&gt;").AddRow(7, "cats & dogs"))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "categories" SET "name" = $1 WHERE (id = $2)`)).
+		WithArgs("<cats>", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, name FROM "tags" WHERE (name LIKE $1)`)).
+		WithArgs("%&%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(5, "&#34;small&#34; &amp;amp; cute"))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "tags" SET "name" = $1 WHERE (id = $2)`)).
+		WithArgs(`"small" &amp; cute`, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(lockQuery).WithArgs(dataMigrationsLock).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(appliedQuery).
+		WithArgs("widen_photos_urls").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "applied_at"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "data_migrations" ("name","applied_at") VALUES ($1,$2) RETURNING "data_migrations"."name"`)).
+		WithArgs("widen_photos_urls", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("widen_photos_urls"))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "pets" ALTER COLUMN "photos_urls" TYPE varchar(2048)[]`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	require.NoError(t, RunDataMigrations(gormDB))
+
+	// the migrations are only applied once
+	for _, name := range []string{"unescape_pet_html", "widen_photos_urls"} {
+		mock.ExpectBegin()
+		mock.ExpectExec(lockQuery).WithArgs(dataMigrationsLock).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(appliedQuery).
+			WithArgs(name).
+			WillReturnRows(sqlmock.NewRows([]string{"name", "applied_at"}).AddRow(name, nil))
+		mock.ExpectRollback()
+	}
+
+	require.NoError(t, RunDataMigrations(gormDB))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
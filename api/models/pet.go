@@ -1,39 +1,56 @@
 package models
 
 import (
-	"html"
+	"encoding/xml"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
 )
 
-// Pet represent a pet saved in our store
+// Pet represent a pet saved in our store.
+// In XML the category is a Category element and the photo urls and the tags are wrapped like in the petstore spec
 type Pet struct {
-	ID         uint64         `gorm:"primary_key;auto_increment" json:"id"`
-	Category   Category       `gorm:"foreignkey:PetID" json:"category"`
-	Name       string         `gorm:"size:255;not null;" json:"name" binding:"required"`
-	PhotosURLs pq.StringArray `gorm:"type:varchar(100)[]" json:"photoUrls"`
-	Tags       []Tag          `gorm:"foreignkey:PetID" json:"tags"`
-	Status     string         `json:"status"`
+	ID         uint64         `gorm:"primary_key;auto_increment" json:"id" xml:"id"`
+	Category   Category       `gorm:"foreignkey:PetID" json:"category" xml:"Category"`
+	Name       string         `gorm:"size:255;not null;" json:"name" xml:"name"`
+	PhotosURLs pq.StringArray `gorm:"type:varchar(2048)[]" json:"photoUrls" xml:"photoUrls>photoUrl"`
+	Tags       []Tag          `gorm:"foreignkey:PetID" json:"tags" xml:"tags>Tag"`
+	Status     string         `json:"status" xml:"status"`
+	CreatedAt  *time.Time     `json:"createdAt,omitempty" xml:"createdAt,omitempty"`
+	UpdatedAt  *time.Time     `json:"updatedAt,omitempty" xml:"updatedAt,omitempty"`
 }
 
-// Sanitise will sanitise the values that will be saved in the database
+// Pets is a list of pets, in XML the Pet elements are wrapped in a pets element
+type Pets []Pet
+
+// MarshalXML will wrap the pets in a pets element, a list of elements without a root is not a valid XML document
+func (p Pets) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "pets"}
+
+	return e.EncodeElement(struct {
+		Pets []Pet `xml:"Pet"`
+	}{p}, start)
+}
+
+// Sanitise will trim the values that will be saved in the database.
+// The values are stored as they were sent, they have to be escaped by the code that puts them in HTML
 func (p *Pet) Sanitise() {
-	p.Name = html.EscapeString(strings.TrimSpace(p.Name))
-	p.Status = html.EscapeString(strings.TrimSpace(p.Status))
+	p.Name = strings.TrimSpace(p.Name)
+	p.Status = strings.TrimSpace(p.Status)
 
-	p.Category.Name = html.EscapeString(strings.TrimSpace(p.Category.Name))
+	p.Category.Name = strings.TrimSpace(p.Category.Name)
 
 	if len(p.Tags) > 0 {
 		for i := range p.Tags {
-			p.Tags[i].Name = html.EscapeString(strings.TrimSpace(p.Tags[i].Name))
+			p.Tags[i].Name = strings.TrimSpace(p.Tags[i].Name)
 			p.Tags[i].PetID = 0
 		}
 	}
 
 	if len(p.PhotosURLs) > 0 {
 		for i := range p.PhotosURLs {
-			p.PhotosURLs[i] = html.EscapeString(strings.TrimSpace(p.PhotosURLs[i]))
+			p.PhotosURLs[i] = strings.TrimSpace(p.PhotosURLs[i])
 		}
 	}
 }
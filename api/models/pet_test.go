@@ -1,15 +1,93 @@
 package models
 
-import "testing"
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/lib/pq"
+)
 
 func TestPetSanitizing(t *testing.T) {
-	newPet := Pet{}
-	newPet.Name = "\"\""
+	newPet := Pet{
+		Name:       " \"Tom\" & Jerry ",
+		Status:     " available\n",
+		Category:   Category{Name: " <cats> "},
+		Tags:       []Tag{{Name: " a&b ", PetID: 3}},
+		PhotosURLs: pq.StringArray{" https://example.com/tom.png?size=1&format=png "},
+	}
 
 	newPet.Sanitise()
 
-	if newPet.Name != "&#34;&#34;" {
-		t.Log(newPet.Name)
-		t.Errorf("a")
+	// the values are only trimmed, they are escaped when they are put in HTML
+	expected := Pet{
+		Name:       "\"Tom\" & Jerry",
+		Status:     "available",
+		Category:   Category{Name: "<cats>"},
+		Tags:       []Tag{{Name: "a&b"}},
+		PhotosURLs: pq.StringArray{"https://example.com/tom.png?size=1&format=png"},
+	}
+	if diff := deep.Equal(newPet, expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPetXML(t *testing.T) {
+	pet := Pet{
+		ID:         1,
+		Category:   Category{ID: 2, Name: "dogs", PetID: 1},
+		Name:       "Rex",
+		PhotosURLs: pq.StringArray{"https://example.com/rex.png"},
+		Tags:       []Tag{{ID: 3, Name: "small", PetID: 1}},
+		Status:     "available",
+	}
+
+	expected := `<Pet><id>1</id><Category><id>2</id><name>dogs</name></Category><name>Rex</name>` +
+		`<photoUrls><photoUrl>https://example.com/rex.png</photoUrl></photoUrls>` +
+		`<tags><Tag><id>3</id><name>small</name></Tag></tags><status>available</status></Pet>`
+
+	encoded, err := xml.Marshal(pet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(encoded) != expected {
+		t.Fatalf("unexpected xml: %s", encoded)
+	}
+
+	decoded := Pet{}
+	err = xml.Unmarshal(encoded, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the ids of the pet are not part of the payload of the category and the tags
+	pet.Category.PetID = 0
+	pet.Tags[0].PetID = 0
+	if diff := deep.Equal(decoded, pet); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPetsXML(t *testing.T) {
+	encoded, err := xml.Marshal(Pets{{ID: 1, Name: "Rex"}, {ID: 2, Name: "Rover"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<pets><Pet><id>1</id><Category><id>0</id><name></name></Category><name>Rex</name><photoUrls></photoUrls><tags></tags><status></status></Pet>` +
+		`<Pet><id>2</id><Category><id>0</id><name></name></Category><name>Rover</name><photoUrls></photoUrls><tags></tags><status></status></Pet></pets>`
+
+	if string(encoded) != expected {
+		t.Fatalf("unexpected xml: %s", encoded)
+	}
+
+	encoded, err = xml.Marshal(Pets{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(encoded) != `<pets></pets>` {
+		t.Fatalf("unexpected xml: %s", encoded)
 	}
 }
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ReplicaPool holds the connections to the read replicas of the database
+// and keeps track of which ones are healthy
+type ReplicaPool struct {
+	replicas []*replica
+	next     uint32
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type replica struct {
+	db      *gorm.DB
+	healthy int32
+}
+
+// NewReplicaPool creates a pool from connections that are already open.
+// Every replica is considered healthy until the first health check says otherwise
+func NewReplicaPool(dbs ...*gorm.DB) *ReplicaPool {
+	pool := &ReplicaPool{stop: make(chan struct{})}
+
+	for _, db := range dbs {
+		pool.replicas = append(pool.replicas, &replica{db: db, healthy: 1})
+	}
+
+	return pool
+}
+
+// OpenReplicaPool will open a connection to every replica listed in the config.
+// A replica that cannot be reached does not prevent the application from starting, it is marked as unhealthy
+// and the health checks will put it back in the pool once it is reachable
+func OpenReplicaPool(c Config) (*ReplicaPool, error) {
+	var dbs []*gorm.DB
+
+	for i, url := range c.DbReplicaURLs {
+		sqlDB, err := sql.Open(c.DbDriver, url)
+		if err != nil {
+			return nil, fmt.Errorf("could not open a connection to the replica %d: %v", i, err)
+		}
+
+		sqlDB.SetMaxOpenConns(c.DbMaxOpenConns)
+		sqlDB.SetMaxIdleConns(c.DbMaxIdleConns)
+		sqlDB.SetConnMaxLifetime(c.DbConnMaxLifetime)
+
+		// gorm pings the database but keeps the connection when it is given an existing *sql.DB
+		db, err := gorm.Open(c.DbDriver, sqlDB)
+		if err != nil {
+			log.Printf("replica %d is not reachable yet: %v", i, err)
+		}
+
+		dbs = append(dbs, db)
+	}
+
+	pool := NewReplicaPool(dbs...)
+	pool.CheckHealth()
+
+	return pool, nil
+}
+
+// Reader will return a healthy replica, replicas are used in turn.
+// It returns nil when there are no healthy replicas so that the caller can fall back to the primary
+func (r *ReplicaPool) Reader() *gorm.DB {
+	if r == nil || len(r.replicas) == 0 {
+		return nil
+	}
+
+	start := atomic.AddUint32(&r.next, 1)
+	for i := 0; i < len(r.replicas); i++ {
+		candidate := r.replicas[(int(start)+i)%len(r.replicas)]
+		if atomic.LoadInt32(&candidate.healthy) == 1 {
+			return candidate.db
+		}
+	}
+
+	return nil
+}
+
+// CheckHealth will ping every replica and update its health status
+func (r *ReplicaPool) CheckHealth() {
+	if r == nil {
+		return
+	}
+
+	for i, candidate := range r.replicas {
+		err := candidate.db.DB().Ping()
+
+		healthy := int32(1)
+		if err != nil {
+			healthy = 0
+		}
+
+		previous := atomic.SwapInt32(&candidate.healthy, healthy)
+		if previous != healthy {
+			if healthy == 1 {
+				log.Printf("replica %d is healthy again", i)
+			} else {
+				log.Printf("replica %d is unhealthy: %v", i, err)
+			}
+		}
+	}
+}
+
+// StartHealthChecks will check the health of the replicas in the background at every interval until Close is called
+func (r *ReplicaPool) StartHealthChecks(interval time.Duration) {
+	if r == nil || len(r.replicas) == 0 || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.CheckHealth()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close will stop the health checks and close the connections to the replicas
+func (r *ReplicaPool) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.stopOnce.Do(func() { close(r.stop) })
+
+	var lastErr error
+	for _, candidate := range r.replicas {
+		err := candidate.db.Close()
+		if err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
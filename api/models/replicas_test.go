@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockGormDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	require.NoError(t, err)
+
+	return gormDB, mock
+}
+
+func TestReplicaPoolReader(t *testing.T) {
+	var nilPool *ReplicaPool
+	require.Nil(t, nilPool.Reader())
+	require.Nil(t, NewReplicaPool().Reader())
+
+	replica1, mock1 := newMockGormDB(t)
+	replica2, mock2 := newMockGormDB(t)
+	pool := NewReplicaPool(replica1, replica2)
+
+	// the replicas are used in turn
+	first := pool.Reader()
+	second := pool.Reader()
+	require.NotNil(t, first)
+	require.NotNil(t, second)
+	require.True(t, first != second)
+
+	// a replica that cannot be pinged is taken out of the pool
+	mock1.ExpectClose()
+	require.NoError(t, replica1.DB().Close())
+	pool.CheckHealth()
+
+	for i := 0; i < 4; i++ {
+		require.True(t, pool.Reader() == replica2)
+	}
+
+	// no healthy replicas left
+	mock2.ExpectClose()
+	require.NoError(t, replica2.DB().Close())
+	pool.CheckHealth()
+	require.Nil(t, pool.Reader())
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SavedSearch is a search saved by an adopter who wants to be told when a matching pet is available.
+// An empty category or an empty list of tags matches every pet
+type SavedSearch struct {
+	ID        uint64         `gorm:"primary_key;auto_increment" json:"id"`
+	Email     string         `gorm:"size:255;not null" json:"email" binding:"required"`
+	Category  string         `gorm:"size:255" json:"category"`
+	Tags      pq.StringArray `gorm:"type:varchar(255)[]" json:"tags"`
+	CreatedAt *time.Time     `json:"createdAt,omitempty"`
+	// Owner is a hash of the api_key that saved the search, the search is only found with the same key.
+	// The searches saved before the owners were recorded have no owner, they can only be notified
+	Owner string `gorm:"size:64;not null;default:'';index" json:"-"`
+}
+
+// SavedSearchNotification records that the owner of a saved search has been told about a pet,
+// so that they are only told once
+type SavedSearchNotification struct {
+	SavedSearchID uint64 `gorm:"primary_key;auto_increment:false"`
+	PetID         uint64 `gorm:"primary_key;auto_increment:false"`
+	CreatedAt     *time.Time
+}
+
+// Sanitise will sanitise the values that will be saved in the database
+func (s *SavedSearch) Sanitise() {
+	s.ID = 0
+	s.CreatedAt = nil
+	s.Owner = ""
+	s.Email = strings.TrimSpace(s.Email)
+	s.Category = strings.TrimSpace(s.Category)
+
+	tags := pq.StringArray{}
+	for _, tag := range s.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	s.Tags = tags
+}
+
+// Validate will make sure that the notifications can be sent to the owner of the saved search,
+// and that the category and the tags fit in their columns
+func (s *SavedSearch) Validate() error {
+	var errs ValidationErrors
+
+	address, err := mail.ParseAddress(s.Email)
+	if err != nil || address.Address != s.Email {
+		errs = append(errs, FieldError{Field: "email", Code: CodeInvalidValue, Message: "must be a valid email address"})
+	}
+
+	errs = append(errs, validateName("category", s.Category, false)...)
+	for i, tag := range s.Tags {
+		errs = append(errs, validateName(fmt.Sprintf("tags[%d]", i), tag, true)...)
+	}
+
+	return errs.asError()
+}
+
+// Matches will tell if the pet is available and has the category and all the tags of the saved search.
+// The names are compared without taking the case into account
+func (s *SavedSearch) Matches(pet *Pet) bool {
+	if pet.Status != "available" {
+		return false
+	}
+
+	if s.Category != "" && !strings.EqualFold(s.Category, pet.Category.Name) {
+		return false
+	}
+
+	for _, wanted := range s.Tags {
+		found := false
+		for _, tag := range pet.Tags {
+			if strings.EqualFold(wanted, tag.Name) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSavedSearchMatches(t *testing.T) {
+	pet := Pet{
+		Name:     "rex",
+		Status:   "available",
+		Category: Category{Name: "Dogs"},
+		Tags:     []Tag{{Name: "small"}, {Name: "cute"}},
+	}
+
+	matching := []SavedSearch{
+		{},
+		{Category: "dogs"},
+		{Tags: []string{"Small"}},
+		{Category: "dogs", Tags: []string{"small", "cute"}},
+	}
+	for _, search := range matching {
+		if !search.Matches(&pet) {
+			t.Errorf("%+v should match the pet", search)
+		}
+	}
+
+	notMatching := []SavedSearch{
+		{Category: "cats"},
+		{Tags: []string{"small", "fluffy"}},
+	}
+	for _, search := range notMatching {
+		if search.Matches(&pet) {
+			t.Errorf("%+v should not match the pet", search)
+		}
+	}
+
+	pet.Status = "sold"
+	search := SavedSearch{}
+	if search.Matches(&pet) {
+		t.Error("a pet that is not available should not match")
+	}
+}
+
+func TestSavedSearchValidation(t *testing.T) {
+	search := SavedSearch{Email: " adopter@example.com ", Tags: []string{" small ", ""}}
+	search.Sanitise()
+
+	if err := search.Validate(); err != nil {
+		t.Fatalf("the saved search should be valid: %v", err)
+	}
+
+	if len(search.Tags) != 1 || search.Tags[0] != "small" {
+		t.Fatalf("the tags should be trimmed: %q", search.Tags)
+	}
+
+	for _, email := range []string{"", "adopter", "Adopter <adopter@example.com>"} {
+		search.Email = email
+		if err := search.Validate(); err == nil {
+			t.Errorf("%q should be an invalid email", email)
+		}
+	}
+
+	// the category and the tags must fit in their columns
+	search = SavedSearch{Email: "adopter@example.com", Category: strings.Repeat("c", 256), Tags: []string{"small", strings.Repeat("t", 256)}}
+	err := search.Validate()
+	expected := ValidationErrors{
+		{Field: "category", Code: CodeTooLong, Message: "cannot be longer than 255 characters"},
+		{Field: "tags[1]", Code: CodeTooLong, Message: "cannot be longer than 255 characters"},
+	}
+	if !reflect.DeepEqual(err, expected) {
+		t.Fatalf("unexpected errors: %v", err)
+	}
+}
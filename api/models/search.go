@@ -0,0 +1,115 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// PetSortFields are the fields that pets can be sorted by in a search
+var PetSortFields = map[string]bool{
+	"id":        true,
+	"name":      true,
+	"status":    true,
+	"createdAt": true,
+	"updatedAt": true,
+}
+
+// PetStatuses are the statuses that a pet can have
+var PetStatuses = map[string]bool{
+	"available": true,
+	"pending":   true,
+	"sold":      true,
+}
+
+// SortField is a field used to sort the results of a search
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// PetSearch holds the filters, the sort order and the pagination of a pet search.
+// Empty filters are ignored
+type PetSearch struct {
+	// Query is a full text search on the name, the category and the tags of the pets
+	Query string
+	// Name matches the pets whose name contains it, the case is ignored
+	Name string
+	// Category matches the pets in the category with this exact name
+	Category string
+	// Tags matches the pets that have any of the tags
+	Tags []string
+	// Statuses matches the pets that have any of the statuses
+	Statuses []string
+
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+
+	Sort   []SortField
+	Limit  int
+	Offset int
+
+	// Facets asks for the number of matches per status, category and tag
+	Facets bool
+}
+
+// Validate will make sure that the search only uses known statuses and sort fields
+func (s *PetSearch) Validate() error {
+	for _, status := range s.Statuses {
+		if !PetStatuses[status] {
+			return fmt.Errorf("status %q is not supported", status)
+		}
+	}
+
+	for _, field := range s.Sort {
+		if !PetSortFields[field.Field] {
+			return fmt.Errorf("cannot sort by %q", field.Field)
+		}
+	}
+
+	if s.Limit <= 0 {
+		return fmt.Errorf("limit must be positive")
+	}
+
+	if s.Offset < 0 {
+		return fmt.Errorf("offset cannot be negative")
+	}
+
+	return nil
+}
+
+// PetSearchResult is a page of pets matching a search
+type PetSearchResult struct {
+	// Total is the number of pets matching the search, on every page
+	Total int   `json:"total"`
+	Pets  []Pet `json:"pets"`
+	// Highlights are only returned for a full text search, in the same order as the pets
+	Highlights []PetHighlight `json:"highlights,omitempty"`
+	// Facets are only returned when they are requested
+	Facets *PetFacets `json:"facets,omitempty"`
+}
+
+// PetFacets are the number of pets matching a search per status, per category and per tag.
+// The values with the most matches come first
+type PetFacets struct {
+	Statuses   []FacetCount `json:"status"`
+	Categories []FacetCount `json:"category"`
+	Tags       []FacetCount `json:"tags"`
+}
+
+// FacetCount is the number of pets matching a search that have the value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// PetHighlight shows where the words of a full text search were found in a pet.
+// The values are escaped for HTML and the matches are wrapped in <mark></mark>
+type PetHighlight struct {
+	PetID    uint64   `json:"petId"`
+	Rank     float64  `json:"rank"`
+	Name     string   `json:"name"`
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
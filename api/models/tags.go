@@ -3,7 +3,7 @@ package models
 // Tag is a tag for a pet
 // ie: small, cute
 type Tag struct {
-	ID uint64 `gorm:"primary_key;not null;unique" json:"id"`
-	Name  string `json:"name"`
-	PetID uint64 `json:"-"`
+	ID    uint64 `gorm:"primary_key;not null;unique" json:"id" xml:"id"`
+	Name  string `json:"name" xml:"name"`
+	PetID uint64 `json:"-" xml:"-"`
 }
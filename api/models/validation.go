@@ -0,0 +1,151 @@
+package models
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxNameLength is the size of the columns storing the names
+	maxNameLength = 255
+	// maxPhotoURLLength is the size of the column storing the photo urls
+	maxPhotoURLLength = 2048
+)
+
+// the codes of the field errors, they do not change and can be used by the clients
+const (
+	CodeRequired     = "required"
+	CodeTooLong      = "too_long"
+	CodeInvalidValue = "invalid_value"
+	CodeInvalidURL   = "invalid_url"
+	CodeInvalidBody  = "invalid_body"
+	CodeTooLarge     = "too_large"
+)
+
+// FieldError explains why the value of a field is invalid
+type FieldError struct {
+	Field   string `json:"field" xml:"field"`
+	Code    string `json:"code" xml:"code"`
+	Message string `json:"message" xml:"message"`
+}
+
+// ValidationErrors are all the problems found in a payload
+type ValidationErrors []FieldError
+
+// Error will list the invalid fields
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, fieldError := range v {
+		messages[i] = fmt.Sprintf("%s: %s", fieldError.Field, fieldError.Message)
+	}
+
+	return strings.Join(messages, ", ")
+}
+
+// MarshalXML will write every field error in an error element
+func (v ValidationErrors) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Errors []FieldError `xml:"error"`
+	}{v}, start)
+}
+
+// asError will return nil when there are no errors, so that the result can be compared to nil by the callers
+func (v ValidationErrors) asError() error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	return v
+}
+
+// Validate will make sure that the pet can be saved
+func (p *Pet) Validate() error {
+	var errs ValidationErrors
+
+	errs = append(errs, validateName("name", p.Name, true)...)
+	errs = append(errs, validateStatus("status", p.Status)...)
+	errs = append(errs, p.Category.validate("category")...)
+
+	for i, tag := range p.Tags {
+		errs = append(errs, tag.validate(fmt.Sprintf("tags[%d]", i))...)
+	}
+
+	for i, photoURL := range p.PhotosURLs {
+		errs = append(errs, validatePhotoURL(fmt.Sprintf("photoUrls[%d]", i), photoURL)...)
+	}
+
+	return errs.asError()
+}
+
+// ValidatePetAttributes will make sure that the name and the status sent in a form can be saved, they are both optional
+func ValidatePetAttributes(name string, status string) error {
+	var errs ValidationErrors
+
+	if name != "" {
+		errs = append(errs, validateName("name", name, true)...)
+	}
+
+	errs = append(errs, validateStatus("status", status)...)
+
+	return errs.asError()
+}
+
+// validate will make sure that the category can be saved, a pet does not need a category
+func (c *Category) validate(field string) ValidationErrors {
+	return validateName(field+".name", c.Name, false)
+}
+
+// validate will make sure that the tag can be saved
+func (t *Tag) validate(field string) ValidationErrors {
+	return validateName(field+".name", t.Name, true)
+}
+
+func validateName(field string, name string, required bool) ValidationErrors {
+	if required && strings.TrimSpace(name) == "" {
+		return ValidationErrors{{Field: field, Code: CodeRequired, Message: "cannot be empty"}}
+	}
+
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return ValidationErrors{{
+			Field:   field,
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("cannot be longer than %d characters", maxNameLength),
+		}}
+	}
+
+	return nil
+}
+
+// validateStatus accepts an empty status, the pet is then not listed by status
+func validateStatus(field string, status string) ValidationErrors {
+	if status != "" && !PetStatuses[status] {
+		return ValidationErrors{{
+			Field:   field,
+			Code:    CodeInvalidValue,
+			Message: "must be one of available, pending or sold",
+		}}
+	}
+
+	return nil
+}
+
+// validatePhotoURL only accepts absolute http and https urls
+func validatePhotoURL(field string, photoURL string) ValidationErrors {
+	if len(photoURL) > maxPhotoURLLength {
+		return ValidationErrors{{
+			Field:   field,
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("cannot be longer than %d characters", maxPhotoURLLength),
+		}}
+	}
+
+	parsed, err := url.Parse(photoURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ValidationErrors{{Field: field, Code: CodeInvalidURL, Message: "must be an absolute http or https url"}}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/lib/pq"
+)
+
+func TestPetValidate(t *testing.T) {
+	validPet := Pet{
+		Name:       "Rex",
+		Status:     "available",
+		Category:   Category{Name: "dogs"},
+		Tags:       []Tag{{Name: "small"}},
+		PhotosURLs: pq.StringArray{"https://example.com/rex.png"},
+	}
+
+	if err := validPet.Validate(); err != nil {
+		t.Fatalf("the pet should be valid: %v", err)
+	}
+
+	// a pet does not need a status, a category, tags or photos
+	if err := (&Pet{Name: "Rex"}).Validate(); err != nil {
+		t.Fatalf("the pet should be valid: %v", err)
+	}
+
+	invalidPet := Pet{
+		Name:       strings.Repeat("a", 256),
+		Status:     "taken",
+		Category:   Category{Name: strings.Repeat("é", 256)},
+		Tags:       []Tag{{Name: "small"}, {Name: ""}},
+		PhotosURLs: pq.StringArray{"ftp://example.com/rex.png", "https://example.com/" + strings.Repeat("a", 2048)},
+	}
+
+	expected := ValidationErrors{
+		{Field: "name", Code: CodeTooLong, Message: "cannot be longer than 255 characters"},
+		{Field: "status", Code: CodeInvalidValue, Message: "must be one of available, pending or sold"},
+		{Field: "category.name", Code: CodeTooLong, Message: "cannot be longer than 255 characters"},
+		{Field: "tags[1].name", Code: CodeRequired, Message: "cannot be empty"},
+		{Field: "photoUrls[0]", Code: CodeInvalidURL, Message: "must be an absolute http or https url"},
+		{Field: "photoUrls[1]", Code: CodeTooLong, Message: "cannot be longer than 2048 characters"},
+	}
+
+	err := invalidPet.Validate()
+	if diff := deep.Equal(err, expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestValidatePetAttributes(t *testing.T) {
+	if err := ValidatePetAttributes("", "sold"); err != nil {
+		t.Fatalf("the attributes should be valid: %v", err)
+	}
+
+	err := ValidatePetAttributes(strings.Repeat("a", 256), "taken")
+	if errs, ok := err.(ValidationErrors); !ok || len(errs) != 2 {
+		t.Fatalf("unexpected errors: %v", err)
+	}
+
+	if err.Error() != "name: cannot be longer than 255 characters, status: must be one of available, pending or sold" {
+		t.Errorf("unexpected message: %s", err.Error())
+	}
+}
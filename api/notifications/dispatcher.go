@@ -0,0 +1,63 @@
+package notifications
+
+import (
+	"log"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/repository"
+)
+
+// Dispatcher finds the saved searches matching a pet and notifies their owners
+type Dispatcher struct {
+	repository repository.SavedSearchRepository
+	notifier   Notifier
+}
+
+// NewDispatcher creates a new Dispatcher
+func NewDispatcher(repository repository.SavedSearchRepository, notifier Notifier) *Dispatcher {
+	return &Dispatcher{
+		repository: repository,
+		notifier:   notifier,
+	}
+}
+
+// PetSaved will notify the owners of the saved searches matching the pet in the background,
+// the request that saved the pet does not wait for the notifications to be sent
+func (d *Dispatcher) PetSaved(pet models.Pet) {
+	go d.Dispatch(pet)
+}
+
+// Dispatch will notify the owners of the saved searches matching the pet.
+// Every owner is only notified once per pet: the notification is claimed before it is sent, so the concurrent saves
+// of a pet cannot both send it. A notification that fails is released and tried again the next time the pet is saved
+func (d *Dispatcher) Dispatch(pet models.Pet) {
+	searches, err := d.repository.FindSavedSearchesToNotify(&pet)
+	if err != nil {
+		log.Printf("failed to find the saved searches matching the pet %d: %v", pet.ID, err)
+		return
+	}
+
+	for _, search := range searches {
+		claimed, err := d.repository.ClaimNotification(search.ID, pet.ID)
+		if err != nil {
+			log.Printf("failed to claim the notification of the saved search %d: %v", search.ID, err)
+			continue
+		}
+
+		if !claimed {
+			// another save of the pet is notifying the owner
+			continue
+		}
+
+		err = d.notifier.Notify(search, pet)
+		if err == nil {
+			continue
+		}
+		log.Printf("failed to notify the owner of the saved search %d: %v", search.ID, err)
+
+		err = d.repository.ReleaseNotification(search.ID, pet.ID)
+		if err != nil {
+			log.Printf("failed to release the notification of the saved search %d: %v", search.ID, err)
+		}
+	}
+}
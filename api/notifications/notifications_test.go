@@ -0,0 +1,205 @@
+package notifications
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/repository"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testSearch = models.SavedSearch{ID: 1, Email: "adopter@example.com", Category: "dogs"}
+	testPet    = models.Pet{
+		ID:       4,
+		Name:     "Rex",
+		Status:   "available",
+		Category: models.Category{Name: "dogs"},
+		Tags:     []models.Tag{{Name: "small"}},
+	}
+)
+
+// fakeSMTPServer accepts a single email without authentication and sends what it received on the channel
+func fakeSMTPServer(t *testing.T) (string, string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	received := make(chan string, 1)
+
+	go func() {
+		defer listener.Close()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) {
+			conn.Write([]byte(line + "\r\n"))
+		}
+
+		var transcript strings.Builder
+		reply("220 localhost fake smtp")
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			transcript.WriteString(line)
+
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 end data with <CR><LF>.<CR><LF>")
+				for {
+					line, err = reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					transcript.WriteString(line)
+				}
+				reply("250 OK")
+			case command == "QUIT":
+				reply("221 bye")
+				received <- transcript.String()
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+
+	return host, port, received
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	host, port, received := fakeSMTPServer(t)
+
+	notifier := NewSMTPNotifier(host, port, "", "", "petstore@example.com")
+
+	err := notifier.Notify(testSearch, testPet)
+	require.NoError(t, err)
+
+	transcript := <-received
+	require.Contains(t, transcript, "MAIL FROM:<petstore@example.com>")
+	require.Contains(t, transcript, "RCPT TO:<adopter@example.com>")
+	require.Contains(t, transcript, "To: adopter@example.com")
+	require.Contains(t, transcript, "Subject: Rex is available for adoption")
+	require.Contains(t, transcript, "Tags: small")
+	require.Contains(t, transcript, "/api/v1/pet/4")
+}
+
+func TestSMTPNotifier_unreachableServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	listener.Close()
+
+	notifier := NewSMTPNotifier(host, port, "", "", "petstore@example.com")
+
+	err = notifier.Notify(testSearch, testPet)
+	require.Error(t, err)
+}
+
+func TestLogNotifier(t *testing.T) {
+	var output bytes.Buffer
+	notifier := NewLogNotifier(log.New(&output, "", 0))
+
+	err := notifier.Notify(testSearch, testPet)
+	require.NoError(t, err)
+	require.Equal(t, "notifying adopter@example.com (saved search 1) that pet 4 \"Rex\" is available\n", output.String())
+}
+
+func TestNewNotifier(t *testing.T) {
+	notifier, err := NewNotifier(models.Config{Notifier: "log"})
+	require.NoError(t, err)
+	require.IsType(t, &LogNotifier{}, notifier)
+
+	notifier, err = NewNotifier(models.Config{Notifier: "smtp", SMTPHost: "localhost", SMTPPort: "25"})
+	require.NoError(t, err)
+	require.IsType(t, &SMTPNotifier{}, notifier)
+
+	_, err = NewNotifier(models.Config{Notifier: "pigeon"})
+	require.Error(t, err)
+}
+
+// fakeNotifier remembers the searches it has been asked to notify and fails for the given emails
+type fakeNotifier struct {
+	notified []uint64
+	failFor  string
+}
+
+func (f *fakeNotifier) Notify(search models.SavedSearch, pet models.Pet) error {
+	if search.Email == f.failFor {
+		return errors.New("mailbox unavailable")
+	}
+
+	f.notified = append(f.notified, search.ID)
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	require.NoError(t, err)
+	defer gormDB.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "saved_searches"`)).
+		WithArgs("dogs", 4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "category", "tags"}).
+			AddRow(1, "adopter@example.com", "dogs", "{}").
+			AddRow(2, "broken@example.com", "", "{}").
+			AddRow(3, "claimed@example.com", "", "{}"))
+
+	// every notification is claimed before it is sent
+	claim := regexp.QuoteMeta(`INSERT INTO saved_search_notifications`)
+	mock.ExpectExec(claim).WithArgs(1, 4, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// the notification that failed is released, it is tried again next time
+	mock.ExpectExec(claim).WithArgs(2, 4, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "saved_search_notifications" WHERE (saved_search_id = $1 AND pet_id = $2)`)).
+		WithArgs(2, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// another save of the pet has claimed the last one
+	mock.ExpectExec(claim).WithArgs(3, 4, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectClose()
+
+	notifier := &fakeNotifier{failFor: "broken@example.com"}
+	dispatcher := NewDispatcher(repository.NewSavedSearchRepository(gormDB), notifier)
+
+	dispatcher.Dispatch(testPet)
+
+	require.Equal(t, []uint64{1}, notifier.notified)
+
+	gormDB.Close()
+	require.NoError(t, mock.ExpectationsWereMet())
+}
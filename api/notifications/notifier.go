@@ -0,0 +1,75 @@
+package notifications
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/YannHulot/petstore/api/models"
+)
+
+// Notifier tells the owner of a saved search that a pet matching it is available
+type Notifier interface {
+	Notify(search models.SavedSearch, pet models.Pet) error
+}
+
+// NewNotifier will create the notifier selected in the config
+func NewNotifier(c models.Config) (Notifier, error) {
+	switch c.Notifier {
+	case "", "log":
+		return NewLogNotifier(log.New(os.Stdout, "", log.LstdFlags)), nil
+	case "smtp":
+		return NewSMTPNotifier(c.SMTPHost, c.SMTPPort, c.SMTPUsername, c.SMTPPassword, c.SMTPFrom), nil
+	default:
+		return nil, fmt.Errorf("notifier %q is not supported", c.Notifier)
+	}
+}
+
+// LogNotifier writes the notifications in a log instead of sending them, it is useful during development
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+// NewLogNotifier creates a new LogNotifier
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	return &LogNotifier{
+		logger: logger,
+	}
+}
+
+// Notify will log the notification
+func (l *LogNotifier) Notify(search models.SavedSearch, pet models.Pet) error {
+	l.logger.Printf("notifying %s (saved search %d) that pet %d %q is available", search.Email, search.ID, pet.ID, pet.Name)
+	return nil
+}
+
+// notificationSubject is the subject of the message sent to the owner of a saved search
+func notificationSubject(pet models.Pet) string {
+	return fmt.Sprintf("%s is available for adoption", pet.Name)
+}
+
+// notificationBody is the text of the message sent to the owner of a saved search
+func notificationBody(search models.SavedSearch, pet models.Pet) string {
+	var body strings.Builder
+
+	fmt.Fprintf(&body, "Hello,\r\n\r\nA pet matching your saved search is available:\r\n\r\n")
+	fmt.Fprintf(&body, "Name: %s\r\n", pet.Name)
+
+	if pet.Category.Name != "" {
+		fmt.Fprintf(&body, "Category: %s\r\n", pet.Category.Name)
+	}
+
+	if len(pet.Tags) > 0 {
+		tags := make([]string, len(pet.Tags))
+		for i, tag := range pet.Tags {
+			tags[i] = tag.Name
+		}
+		fmt.Fprintf(&body, "Tags: %s\r\n", strings.Join(tags, ", "))
+	}
+
+	fmt.Fprintf(&body, "\r\nSee /api/v1/pet/%d for more details.\r\n", pet.ID)
+	fmt.Fprintf(&body, "You received this message because of the saved search %d.\r\n", search.ID)
+
+	return body.String()
+}
@@ -0,0 +1,58 @@
+package notifications
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/YannHulot/petstore/api/models"
+)
+
+// SMTPNotifier sends the notifications by email
+type SMTPNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPNotifier creates a new SMTPNotifier.
+// The notifier authenticates with the server when a username is given
+func NewSMTPNotifier(host string, port string, username string, password string, from string) *SMTPNotifier {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPNotifier{
+		addr: net.JoinHostPort(host, port),
+		from: from,
+		auth: auth,
+	}
+}
+
+// Notify will send an email to the owner of the saved search
+func (s *SMTPNotifier) Notify(search models.SavedSearch, pet models.Pet) error {
+	err := smtp.SendMail(s.addr, s.auth, s.from, []string{search.Email}, s.message(search, pet))
+	if err != nil {
+		return fmt.Errorf("could not send the email to %s: %v", search.Email, err)
+	}
+
+	return nil
+}
+
+// message builds the email, the headers cannot contain new lines so the values coming from the users are encoded
+func (s *SMTPNotifier) message(search models.SavedSearch, pet models.Pet) []byte {
+	headers := []string{
+		"From: " + s.from,
+		"To: " + search.Email,
+		"Subject: " + mime.QEncoding.Encode("utf-8", notificationSubject(pet)),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+	}
+
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + notificationBody(search, pet))
+}
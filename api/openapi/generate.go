@@ -0,0 +1,83 @@
+package openapi
+
+import (
+	"strings"
+)
+
+// Route is a route of a router with the operations that describe it.
+// The path is a gin path, e.g. /api/v1/pet/:id
+type Route struct {
+	Method     string
+	Path       string
+	Operations []Operation
+}
+
+// Generate will describe the routes with their operations,
+// the routes that have no operation are left out of the document.
+// The path parameters that the operations do not describe are added as required strings
+func Generate(info Info, routes []Route, components Components) Document {
+	document := Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      map[string]PathItem{},
+		Components: components,
+	}
+
+	for _, route := range routes {
+		for _, operation := range route.Operations {
+			operation := operation
+			operation.Parameters = append([]Parameter{}, operation.Parameters...)
+
+			path := route.Path
+			if operation.Path != "" {
+				path = operation.Path
+			}
+
+			path, params := templatePath(path)
+			for _, param := range params {
+				if !hasParameter(operation.Parameters, param, "path") {
+					operation.Parameters = append(operation.Parameters, Parameter{
+						Name:     param,
+						In:       "path",
+						Required: true,
+						Schema:   &Schema{Type: "string"},
+					})
+				}
+			}
+
+			if document.Paths[path] == nil {
+				document.Paths[path] = PathItem{}
+			}
+			document.Paths[path][strings.ToLower(route.Method)] = &operation
+		}
+	}
+
+	return document
+}
+
+// templatePath will turn the parameters of a gin path into OpenAPI templates, e.g. /pet/:id becomes /pet/{id}.
+// It also returns the names of the parameters
+func templatePath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+// hasParameter will tell if the parameter is in the list
+func hasParameter(parameters []Parameter, name, in string) bool {
+	for _, parameter := range parameters {
+		if parameter.Name == name && parameter.In == in {
+			return true
+		}
+	}
+
+	return false
+}
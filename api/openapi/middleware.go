@@ -0,0 +1,367 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/gin-gonic/gin"
+)
+
+// maxFormMemory is the part of a multipart form kept in memory, like gin does
+const maxFormMemory = 32 << 20
+
+// Validator checks the requests against an OpenAPI document before they reach the handlers.
+// In strict mode the responses are checked too, a response that does not match the document is replaced by a 500
+type Validator struct {
+	describe func() Document
+	strict   bool
+
+	once     sync.Once
+	document Document
+}
+
+// NewValidator will create a validator of the document returned by describe.
+// The document is described on the first request, once all the routes have been registered
+func NewValidator(describe func() Document, strict bool) *Validator {
+	return &Validator{describe: describe, strict: strict}
+}
+
+// Document returns the document the requests are validated against
+func (v *Validator) Document() Document {
+	v.once.Do(func() {
+		v.document = v.describe()
+	})
+
+	return v.document
+}
+
+// Validate is the middleware, the requests that the document does not describe are left to the router
+func (v *Validator) Validate(c *gin.Context) {
+	document := v.Document()
+
+	operation, pathParams := document.find(c.Request.Method, c.Request.URL.Path)
+	if operation == nil {
+		c.Next()
+		return
+	}
+
+	if !responses.LimitBody(c, operation.MaxBodySize) {
+		return
+	}
+
+	if message := document.invalidMessage(operation, pathParams); message != "" {
+		responses.Error(c, http.StatusBadRequest, message)
+		return
+	}
+
+	errs, bodyErrs := document.validateRequest(c.Request, operation, pathParams)
+	for _, err := range errs {
+		if err.Code == models.CodeTooLarge {
+			responses.Error(c, http.StatusRequestEntityTooLarge, "The body "+err.Message)
+			return
+		}
+	}
+
+	if len(errs) > 0 {
+		responses.ValidationError(c, append(errs, bodyErrs...))
+		return
+	}
+
+	if len(bodyErrs) > 0 {
+		// a well formed body that does not match its schema is unprocessable when the operation says so
+		if operation.InvalidBodyStatus == http.StatusUnprocessableEntity {
+			responses.UnprocessableEntity(c, bodyErrs)
+		} else {
+			responses.ValidationError(c, bodyErrs)
+		}
+		return
+	}
+
+	if !v.strict {
+		c.Next()
+		return
+	}
+
+	writer := responses.NewBufferedWriter(c.Writer)
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+
+	err := document.validateResponse(operation, writer.Status(), writer.Header().Get("Content-Type"), writer.Body())
+	if err != nil {
+		log.Printf("the response of %s %s does not match the OpenAPI document: %v", c.Request.Method, c.Request.URL.Path, err)
+		writer.Header().Del("Content-Type")
+		responses.InternalError(c)
+		return
+	}
+
+	writer.Send()
+}
+
+// readError will explain why the body of the request could not be read
+func readError(r *http.Request, message string) models.ValidationErrors {
+	if limit, exceeded := responses.BodyLimitExceeded(r); exceeded {
+		return models.ValidationErrors{{
+			Field:   "body",
+			Code:    models.CodeTooLarge,
+			Message: fmt.Sprintf("cannot be larger than %d bytes", limit),
+		}}
+	}
+
+	return models.ValidationErrors{{Field: "body", Code: models.CodeInvalidBody, Message: message}}
+}
+
+// find will return the operation of the request and the values of its path parameters.
+// The paths with the most literal segments win, so /pet/findByStatus is preferred to /pet/{id}
+func (d *Document) find(method, path string) (*Operation, map[string]string) {
+	segments := strings.Split(path, "/")
+
+	var found *Operation
+	var foundParams map[string]string
+	mostLiterals := -1
+
+	for template, item := range d.Paths {
+		operation := item[strings.ToLower(method)]
+		if operation == nil {
+			continue
+		}
+
+		templateSegments := strings.Split(template, "/")
+		if len(templateSegments) != len(segments) {
+			continue
+		}
+
+		literals := 0
+		params := map[string]string{}
+		matches := true
+		for i, segment := range templateSegments {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				params[segment[1:len(segment)-1]] = segments[i]
+				continue
+			}
+
+			if segment != segments[i] {
+				matches = false
+				break
+			}
+			literals++
+		}
+
+		if matches && literals > mostLiterals {
+			found, foundParams, mostLiterals = operation, params, literals
+		}
+	}
+
+	return found, foundParams
+}
+
+// validateRequest will check the parameters and the body of the request.
+// The errors of the parameters and the malformed bodies are returned first, then the errors of the well formed bodies
+func (d *Document) validateRequest(r *http.Request, operation *Operation, pathParams map[string]string) (models.ValidationErrors, models.ValidationErrors) {
+	var errs models.ValidationErrors
+	query := r.URL.Query()
+
+	for _, param := range operation.Parameters {
+		var values []string
+		switch param.In {
+		case "path":
+			values = []string{pathParams[param.Name]}
+		case "query":
+			values = query[param.Name]
+		case "header":
+			values = r.Header[http.CanonicalHeaderKey(param.Name)]
+		}
+
+		if len(values) == 0 {
+			if param.Required {
+				errs = append(errs, models.FieldError{Field: param.Name, Code: models.CodeRequired, Message: "is required"})
+			}
+			continue
+		}
+
+		errs = append(errs, d.validateParameter(param.Name, param.Schema, values)...)
+	}
+
+	if operation.RequestBody == nil {
+		return errs, nil
+	}
+
+	bodyErrs := d.validateBody(r, operation.RequestBody)
+	if len(bodyErrs) == 1 && (bodyErrs[0].Code == models.CodeInvalidBody || bodyErrs[0].Code == models.CodeTooLarge) {
+		return append(errs, bodyErrs...), nil
+	}
+
+	return errs, bodyErrs
+}
+
+// invalidMessage returns the message of the first invalid path parameter that has one, e.g the Invalid ID supplied
+// of the petstore, or an empty string
+func (d *Document) invalidMessage(operation *Operation, pathParams map[string]string) string {
+	for _, param := range operation.Parameters {
+		if param.In != "path" || param.InvalidMessage == "" {
+			continue
+		}
+
+		if len(d.validateParameter(param.Name, param.Schema, []string{pathParams[param.Name]})) > 0 {
+			return param.InvalidMessage
+		}
+	}
+
+	return ""
+}
+
+// validateBody will check the JSON and form bodies.
+// The bodies in other formats, like XML, and the media types the operation does not describe are left to the handlers.
+// A request without a content type is read as JSON, like the handlers do
+func (d *Document) validateBody(r *http.Request, body *RequestBody) models.ValidationErrors {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "application/json"
+	}
+
+	content, ok := body.Content[mediaType]
+	if !ok {
+		return nil
+	}
+
+	switch {
+	case isJSON(mediaType):
+		if r.Body == nil {
+			// only the requests built by a client, e.g in the tests, have no body at all
+			r.Body = http.NoBody
+		}
+
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return readError(r, "could not be read")
+		}
+		// the handler reads the body again
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+		value, err := decodeJSON(data)
+		if err != nil {
+			return models.ValidationErrors{{Field: "body", Code: models.CodeInvalidBody, Message: "is not a valid JSON document"}}
+		}
+
+		return d.validateValue("body", content.Schema, value)
+	case mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data":
+		return d.validateForm(r, content.Schema)
+	}
+
+	return nil
+}
+
+// validateForm will check the fields of a form, the files are only required.
+// The parsed form is kept in the request and used by the handlers
+func (d *Document) validateForm(r *http.Request, schema *Schema) models.ValidationErrors {
+	// ParseMultipartForm does not return the errors of the url encoded forms, they are parsed first
+	err := r.ParseForm()
+	if err == nil {
+		err = r.ParseMultipartForm(maxFormMemory)
+	}
+	if err != nil && err != http.ErrNotMultipart {
+		return readError(r, "is not a valid form")
+	}
+
+	schema = d.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+
+	var errs models.ValidationErrors
+	for _, name := range schema.Required {
+		if len(r.PostForm[name]) == 0 && (r.MultipartForm == nil || len(r.MultipartForm.File[name]) == 0) {
+			errs = append(errs, models.FieldError{Field: name, Code: models.CodeRequired, Message: "is required"})
+		}
+	}
+
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property := schema.Properties[name]
+		if property.Format == "binary" {
+			continue
+		}
+
+		errs = append(errs, d.validateParameter(name, property, r.PostForm[name])...)
+	}
+
+	return errs
+}
+
+// validateResponse will make sure that the status code and the content type of the response are documented,
+// and that the JSON bodies match their schema
+func (d *Document) validateResponse(operation *Operation, status int, contentType string, body []byte) error {
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		response, ok = operation.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("status %d is not documented", status)
+	}
+
+	if len(body) == 0 {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("the content type %q of the status %d is invalid", contentType, status)
+	}
+
+	content, ok := findContent(response, mediaType)
+	if !ok {
+		return fmt.Errorf("the content type %q of the status %d is not documented", mediaType, status)
+	}
+
+	if !isJSON(mediaType) {
+		return nil
+	}
+
+	value, err := decodeJSON(body)
+	if err != nil {
+		return fmt.Errorf("the body of the status %d is not a valid JSON document: %v", status, err)
+	}
+
+	if errs := d.validateValue("body", content.Schema, value); len(errs) > 0 {
+		return fmt.Errorf("the body of the status %d is invalid: %v", status, errs)
+	}
+
+	return nil
+}
+
+// findContent will find the content of the media type in the response, the media ranges like image/* are matched too
+func findContent(response Response, mediaType string) (MediaType, bool) {
+	candidates := []string{mediaType}
+	if slash := strings.Index(mediaType, "/"); slash > 0 {
+		candidates = append(candidates, mediaType[:slash]+"/*")
+	}
+	candidates = append(candidates, "*/*")
+
+	for _, candidate := range candidates {
+		if content, ok := response.Content[candidate]; ok {
+			return content, true
+		}
+	}
+
+	return MediaType{}, false
+}
+
+// isJSON will tell if the media type is JSON, e.g. application/json or application/merge-patch+json
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
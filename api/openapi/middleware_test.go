@@ -0,0 +1,229 @@
+package openapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type pet struct {
+	ID     uint64 `json:"id"`
+	Name   string `json:"name" binding:"required"`
+	Status string `json:"status"`
+}
+
+func testDocument() Document {
+	schemas := Schemas{}
+	petSchema := schemas.Of(pet{})
+	schemas["pet"].Properties["status"].Enum = []string{"available", "sold"}
+
+	idParam := Parameter{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer", Minimum: int64Ptr(1)}}
+	ok := Response{Description: "ok", Content: map[string]MediaType{
+		"application/json": {Schema: petSchema},
+		"image/*":          {Schema: &Schema{Type: "string", Format: "binary"}},
+	}}
+
+	return Generate(Info{Title: "test", Version: "1"}, []Route{
+		{Method: "GET", Path: "/pet/:id", Operations: []Operation{
+			{Parameters: []Parameter{idParam}, Responses: map[string]Response{"200": ok}},
+			{Path: "/pet/findByStatus", Parameters: []Parameter{{
+				Name: "status", In: "query", Required: true,
+				Schema: &Schema{Type: "array", Items: &Schema{Type: "string", Enum: []string{"available", "sold"}}},
+			}}, Responses: map[string]Response{"200": ok}},
+		}},
+		{Method: "POST", Path: "/pet", Operations: []Operation{{
+			RequestBody: &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: petSchema}}},
+			Responses:   map[string]Response{"200": ok},
+		}}},
+		{Method: "POST", Path: "/pet/:id", Operations: []Operation{{
+			Parameters: []Parameter{idParam},
+			RequestBody: &RequestBody{Content: map[string]MediaType{"application/x-www-form-urlencoded": {Schema: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{"name": {Type: "string", MaxLength: intPtr(5)}},
+				Required:   []string{"name"},
+			}}}},
+			Responses: map[string]Response{"200": ok},
+		}}},
+	}, Components{Schemas: schemas})
+}
+
+func newTestRouter(strict bool, handler gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
+	r.Use(NewValidator(testDocument, strict).Validate)
+	r.GET("/pet/:id", handler)
+	r.POST("/pet", handler)
+	r.POST("/pet/:id", handler)
+	r.GET("/other", handler)
+
+	return r
+}
+
+func request(r *gin.Engine, method, path, contentType, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func echo(c *gin.Context) {
+	body, _ := ioutil.ReadAll(c.Request.Body)
+	c.Data(http.StatusOK, "application/json", body)
+}
+
+func TestValidateRequest(t *testing.T) {
+	r := newTestRouter(false, echo)
+
+	for _, test := range []struct {
+		method, path, contentType, body string
+		code                            int
+		response                        string
+	}{
+		{"GET", "/pet/abc", "", "", 400, `{"field":"id","code":"invalid_value","message":"must be an integer"}`},
+		{"GET", "/pet/0", "", "", 400, `{"field":"id","code":"invalid_value","message":"must be at least 1"}`},
+		{"GET", "/pet/1", "", "", 200, ``},
+		{"GET", "/pet/findByStatus", "", "", 400, `{"field":"status","code":"required","message":"is required"}`},
+		{"GET", "/pet/findByStatus?status=sold&status=lost", "", "", 400, `{"field":"status","code":"invalid_value","message":"must be one of available or sold"}`},
+		{"GET", "/pet/findByStatus?status=sold&status=available", "", "", 200, ``},
+		{"POST", "/pet", "application/json", `{"name":`, 400, `{"field":"body","code":"invalid_body","message":"is not a valid JSON document"}`},
+		{"POST", "/pet", "application/json", `{"id":"1","status":"lost"}`, 400, `{"field":"name","code":"required","message":"is required"},{"field":"id","code":"invalid_value","message":"must be an integer"},{"field":"status","code":"invalid_value","message":"must be one of available or sold"}`},
+		// without a content type the body is read as JSON
+		{"POST", "/pet", "", `{"name":"rex"}`, 200, `{"name":"rex"}`},
+		// the XML bodies are left to the handlers
+		{"POST", "/pet", "application/xml", `<Pet></Pet>`, 200, `<Pet></Pet>`},
+		{"POST", "/pet/1", "application/x-www-form-urlencoded", `name=doggie`, 400, `{"field":"name","code":"too_long","message":"cannot be longer than 5 characters"}`},
+		{"POST", "/pet/1", "application/x-www-form-urlencoded", `status=sold`, 400, `{"field":"name","code":"required","message":"is required"}`},
+		{"GET", "/other?status=lost", "", "", 200, ``},
+	} {
+		recorder := request(r, test.method, test.path, test.contentType, test.body)
+		require.Equal(t, test.code, recorder.Code, test.path)
+
+		if test.code == http.StatusBadRequest {
+			require.JSONEq(t, `{"code":400,"type":"error","message":"Invalid input","errors":[`+test.response+`]}`,
+				recorder.Body.String(), test.path)
+		} else {
+			require.Equal(t, test.response, recorder.Body.String(), test.path)
+		}
+	}
+}
+
+func TestValidateRequestInvalidMessage(t *testing.T) {
+	document := testDocument()
+	document.Paths["/pet/{id}"]["get"].Parameters[0].InvalidMessage = "Invalid ID supplied"
+
+	r := gin.New()
+	r.Use(NewValidator(func() Document { return document }, false).Validate)
+	r.GET("/pet/:id", echo)
+
+	// the invalid path parameters with a message are answered with it alone
+	for _, path := range []string{"/pet/abc", "/pet/0"} {
+		recorder := request(r, "GET", path, "", "")
+		require.Equal(t, http.StatusBadRequest, recorder.Code, path)
+		require.JSONEq(t, `{"code":400,"type":"error","message":"Invalid ID supplied"}`, recorder.Body.String(), path)
+	}
+
+	require.Equal(t, http.StatusOK, request(r, "GET", "/pet/1", "", "").Code)
+}
+
+func TestValidateRequestUnprocessable(t *testing.T) {
+	document := testDocument()
+	document.Paths["/pet"]["post"].InvalidBodyStatus = http.StatusUnprocessableEntity
+
+	r := gin.New()
+	r.Use(NewValidator(func() Document { return document }, false).Validate)
+	r.POST("/pet", echo)
+
+	// the bodies that do not match their schema are unprocessable when the operation says so
+	recorder := request(r, "POST", "/pet", "application/json", `{"status":"lost"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	require.JSONEq(t, `{"code":422,"type":"error","message":"Invalid input","errors":[`+
+		`{"field":"name","code":"required","message":"is required"},`+
+		`{"field":"status","code":"invalid_value","message":"must be one of available or sold"}]}`, recorder.Body.String())
+
+	// the malformed ones are still bad requests
+	recorder = request(r, "POST", "/pet", "application/json", `{"name":`)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestValidateRequestBodySize(t *testing.T) {
+	document := testDocument()
+	document.Paths["/pet"]["post"].MaxBodySize = 20
+	document.Paths["/pet/{id}"]["post"].MaxBodySize = 20
+
+	r := gin.New()
+	r.Use(NewValidator(func() Document { return document }, false).Validate)
+	r.POST("/pet", echo)
+	r.POST("/pet/:id", echo)
+
+	recorder := request(r, "POST", "/pet", "application/json", `{"name":"rex"}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// the Content-Length is checked before the body is read
+	recorder = request(r, "POST", "/pet", "application/json", `{"name":"rex the dog"}`)
+	require.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+	require.JSONEq(t, `{"code":413,"type":"error","message":"The body cannot be larger than 20 bytes"}`, recorder.Body.String())
+
+	// the bodies of unknown size stop being read at the limit
+	for path, contentType := range map[string]string{
+		"/pet":   "application/json",
+		"/pet/1": "application/x-www-form-urlencoded",
+	} {
+		req, _ := http.NewRequest("POST", path, ioutil.NopCloser(strings.NewReader(`name=rex&status=available`)))
+		req.Header.Set("Content-Type", contentType)
+		require.Equal(t, int64(0), req.ContentLength)
+
+		recorder = httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code, path)
+		require.JSONEq(t, `{"code":413,"type":"error","message":"The body cannot be larger than 20 bytes"}`, recorder.Body.String(), path)
+	}
+
+	// a body of unknown size at the limit is read whole
+	req, _ := http.NewRequest("POST", "/pet", ioutil.NopCloser(strings.NewReader(`{"name":"rex the d"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+}
+
+func TestValidateResponse(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		handler gin.HandlerFunc
+		code    int
+	}{
+		{"documented", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"id": 1, "name": "rex"}) }, 200},
+		{"invalid body", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"id": "1"}) }, 500},
+		{"undocumented status", func(c *gin.Context) { c.JSON(http.StatusCreated, gin.H{"name": "rex"}) }, 500},
+		{"undocumented content type", func(c *gin.Context) { c.String(http.StatusOK, "rex") }, 500},
+		{"media range", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", []byte("\x89PNG")) }, 200},
+	} {
+		recorder := request(newTestRouter(true, test.handler), "GET", "/pet/1", "", "")
+		require.Equal(t, test.code, recorder.Code, test.name)
+
+		if test.code == http.StatusInternalServerError {
+			require.JSONEq(t, `{"code":500,"type":"error","message":"Internal server error"}`, recorder.Body.String(), test.name)
+		}
+	}
+
+	// the responses are not checked outside of the strict mode
+	recorder := request(newTestRouter(false, func(c *gin.Context) { c.JSON(http.StatusCreated, gin.H{}) }), "GET", "/pet/1", "", "")
+	require.Equal(t, http.StatusCreated, recorder.Code)
+}
+
+func int64Ptr(value int64) *int64 {
+	return &value
+}
+
+func intPtr(value int) *int {
+	return &value
+}
@@ -0,0 +1,113 @@
+package openapi
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type owner struct {
+	Name    string `json:"name" binding:"required"`
+	private string
+}
+
+type animal struct {
+	ID       uint64     `json:"id"`
+	Owner    owner      `json:"owner"`
+	Friends  []animal   `json:"friends,omitempty"`
+	Weight   float64    `json:"weight"`
+	Born     *time.Time `json:"born,omitempty"`
+	Vaccined bool
+	Secret   string `json:"-"`
+}
+
+func TestSchemasOf(t *testing.T) {
+	schemas := Schemas{}
+
+	require.Equal(t, &Schema{Ref: "#/components/schemas/animal"}, schemas.Of(animal{}))
+	require.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/animal"}}, schemas.Of([]*animal{}))
+
+	require.Equal(t, &Schema{Type: "object", Properties: map[string]*Schema{
+		"id":       {Type: "integer", Format: "int64"},
+		"owner":    {Ref: "#/components/schemas/owner"},
+		"friends":  {Type: "array", Items: &Schema{Ref: "#/components/schemas/animal"}},
+		"weight":   {Type: "number", Format: "double"},
+		"born":     {Type: "string", Format: "date-time"},
+		"Vaccined": {Type: "boolean"},
+	}}, schemas["animal"])
+
+	require.Equal(t, &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{"name": {Type: "string"}},
+		Required:   []string{"name"},
+	}, schemas["owner"])
+}
+
+type kennel struct {
+	XMLName xml.Name `json:"-" xml:"Kennel"`
+	Name    string   `gorm:"size:50" json:"name" xml:"label"`
+	Photos  []string `gorm:"type:varchar(20)[]" json:"photos" xml:"photos>photo"`
+	Owners  []owner  `json:"owners" xml:"owners>owner"`
+}
+
+type cage struct {
+	Size int `json:"size" xml:"size"`
+}
+
+func TestSchemasOfTags(t *testing.T) {
+	schemas := Schemas{}
+	schemas.Of(kennel{})
+	schemas.Of(cage{})
+
+	require.Equal(t, &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"name": {Type: "string", MaxLength: intPtr(50), XML: &XML{Name: "label"}},
+			"photos": {
+				Type:  "array",
+				Items: &Schema{Type: "string", MaxLength: intPtr(20), XML: &XML{Name: "photo"}},
+				XML:   &XML{Name: "photos", Wrapped: true},
+			},
+			// the elements of the references are named by the schema they refer to
+			"owners": {
+				Type:  "array",
+				Items: &Schema{Ref: "#/components/schemas/owner"},
+				XML:   &XML{Name: "owners", Wrapped: true},
+			},
+		},
+		XML: &XML{Name: "Kennel"},
+	}, schemas["kennel"])
+
+	// the structs without an XMLName are named after their type, the types without xml tags are not encoded in XML
+	require.Equal(t, &XML{Name: "cage"}, schemas["cage"].XML)
+	require.Nil(t, schemas["owner"].XML)
+}
+
+func TestGenerate(t *testing.T) {
+	idParam := Parameter{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer"}}
+	routes := []Route{
+		{Method: "GET", Path: "/pet/:id", Operations: []Operation{
+			{OperationID: "getPet", Parameters: []Parameter{idParam}},
+			{OperationID: "findByStatus", Path: "/pet/findByStatus"},
+		}},
+		{Method: "DELETE", Path: "/pet/:id", Operations: []Operation{{OperationID: "deletePet"}}},
+		{Method: "GET", Path: "/docs/*filepath"},
+	}
+
+	document := Generate(Info{Title: "test", Version: "1"}, routes, Components{})
+
+	require.Equal(t, Version, document.OpenAPI)
+	require.Len(t, document.Paths, 2)
+
+	require.Equal(t, "getPet", document.Paths["/pet/{id}"]["get"].OperationID)
+	require.Equal(t, []Parameter{idParam}, document.Paths["/pet/{id}"]["get"].Parameters)
+
+	// the parameters that are not described are added as strings
+	require.Equal(t, []Parameter{{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}}},
+		document.Paths["/pet/{id}"]["delete"].Parameters)
+
+	require.Equal(t, "findByStatus", document.Paths["/pet/findByStatus"]["get"].OperationID)
+	require.Empty(t, document.Paths["/pet/findByStatus"]["get"].Parameters)
+}
@@ -0,0 +1,162 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeType is described as a date-time string, like encoding/json writes it
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schemas are the schemas of the components, keyed by the name of the Go type they were made from
+type Schemas map[string]*Schema
+
+// Of will describe the JSON encoding of the value.
+// The structs are added to the schemas and a reference to them is returned
+func (s Schemas) Of(value interface{}) *Schema {
+	return s.of(reflect.TypeOf(value))
+}
+
+func (s Schemas) of(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Struct:
+		return s.ofStruct(t)
+	default:
+		// maps and interfaces can hold anything
+		return &Schema{Type: "object"}
+	}
+}
+
+// ofStruct will add the schema of the struct to the components and return a reference to it.
+// The fields are named after their json tag, the fields with a required binding are required.
+// The strings are limited to the size of their gorm column, and the XML names come from the xml tags
+func (s Schemas) ofStruct(t reflect.Type) *Schema {
+	ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+	if _, described := s[t.Name()]; described {
+		return ref
+	}
+
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	// add the schema before describing the fields, so that recursive types end up referring to themselves
+	s[t.Name()] = schema
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if field.Name == "XMLName" {
+			schema.XML = &XML{Name: strings.Split(field.Tag.Get("xml"), ",")[0]}
+			continue
+		}
+		// like encoding/xml, the elements of the structs without an XMLName are named after their type
+		if field.Tag.Get("xml") != "" && schema.XML == nil {
+			schema.XML = &XML{Name: t.Name()}
+		}
+
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			name = strings.Split(tag, ",")[0]
+		}
+		if name == "-" {
+			continue
+		}
+
+		property := s.of(field.Type)
+		if length, limited := columnLength(field.Tag.Get("gorm")); limited {
+			limitLength(property, length)
+		}
+		describeXML(property, name, field.Tag.Get("xml"))
+
+		schema.Properties[name] = property
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return ref
+}
+
+// columnLength will find the length of the column in a gorm tag, e.g. size:255 or type:varchar(255)[]
+func columnLength(tag string) (int, bool) {
+	for _, setting := range strings.Split(tag, ";") {
+		setting = strings.TrimSpace(setting)
+
+		value := ""
+		switch {
+		case strings.HasPrefix(setting, "size:"):
+			value = strings.TrimPrefix(setting, "size:")
+		case strings.HasPrefix(setting, "type:varchar("):
+			value = strings.TrimPrefix(setting, "type:varchar(")
+			if end := strings.Index(value, ")"); end >= 0 {
+				value = value[:end]
+			}
+		default:
+			continue
+		}
+
+		length, err := strconv.Atoi(value)
+		if err == nil {
+			return length, true
+		}
+	}
+
+	return 0, false
+}
+
+// limitLength sets the maximum length of a string, or of the strings of an array
+func limitLength(schema *Schema, length int) {
+	if schema.Type == "array" {
+		schema = schema.Items
+	}
+	if schema.Type == "string" && schema.Format == "" {
+		schema.MaxLength = &length
+	}
+}
+
+// describeXML will name the element of a property after its xml tag when it differs from the JSON name.
+// The arrays tagged with a parent (e.g. photoUrls>photoUrl) are wrapped in it.
+// The references cannot be renamed, their elements are named by their own schema
+func describeXML(property *Schema, name, tag string) {
+	element := strings.Split(tag, ",")[0]
+	if element == "" || element == "-" {
+		return
+	}
+
+	if parts := strings.Split(element, ">"); len(parts) == 2 && property.Type == "array" {
+		property.XML = &XML{Name: parts[0], Wrapped: true}
+		if property.Items.Ref == "" {
+			property.Items.XML = &XML{Name: parts[1]}
+		}
+		return
+	}
+
+	if property.Ref == "" && element != name {
+		property.XML = &XML{Name: element}
+	}
+}
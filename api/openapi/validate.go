@@ -0,0 +1,245 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/YannHulot/petstore/api/models"
+)
+
+// refPrefix is the start of the references to the schemas of the components
+const refPrefix = "#/components/schemas/"
+
+// resolve will follow the reference of the schema
+func (d *Document) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = d.Components.Schemas[strings.TrimPrefix(schema.Ref, refPrefix)]
+	}
+
+	return schema
+}
+
+// decodeJSON will decode a JSON document, the numbers are kept as json.Number so that integers can be told apart
+func decodeJSON(body []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// validateValue will check a decoded JSON value against the schema.
+// A null value is accepted where the property is optional, like encoding/json does
+func (d *Document) validateValue(field string, schema *Schema, value interface{}) models.ValidationErrors {
+	schema = d.resolve(schema)
+	if schema == nil || value == nil {
+		return nil
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return invalid(field, "must be an object")
+		}
+
+		var errs models.ValidationErrors
+		for _, name := range schema.Required {
+			if object[name] == nil {
+				errs = append(errs, models.FieldError{Field: join(field, name), Code: models.CodeRequired, Message: "is required"})
+			}
+		}
+
+		// the properties are checked in a stable order, so that the errors always come in the same order
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			errs = append(errs, d.validateValue(join(field, name), schema.Properties[name], object[name])...)
+		}
+
+		return errs
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return invalid(field, "must be an array")
+		}
+
+		var errs models.ValidationErrors
+		for i, item := range array {
+			errs = append(errs, d.validateValue(fmt.Sprintf("%s[%d]", field, i), schema.Items, item)...)
+		}
+
+		return errs
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return invalid(field, "must be a string")
+		}
+
+		return validateString(field, schema, text)
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return invalid(field, "must be "+article(schema.Type))
+		}
+
+		return validateNumber(field, schema, number.String())
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return invalid(field, "must be a boolean")
+		}
+	}
+
+	return nil
+}
+
+// validateParameter will check the values of a path, query or header parameter, they are always strings
+func (d *Document) validateParameter(field string, schema *Schema, values []string) models.ValidationErrors {
+	schema = d.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+
+	if schema.Type == "array" {
+		var errs models.ValidationErrors
+		for _, value := range values {
+			errs = append(errs, d.validateParameter(field, schema.Items, []string{value})...)
+		}
+
+		return errs
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	switch schema.Type {
+	case "integer", "number":
+		return validateNumber(field, schema, values[0])
+	case "boolean":
+		if _, err := strconv.ParseBool(values[0]); err != nil {
+			return invalid(field, "must be a boolean")
+		}
+	case "string":
+		return validateString(field, schema, values[0])
+	}
+
+	return nil
+}
+
+func validateString(field string, schema *Schema, text string) models.ValidationErrors {
+	if len(schema.Enum) > 0 && !contains(schema.Enum, text) {
+		return invalid(field, "must be one of "+oneOf(schema.Enum))
+	}
+
+	if schema.MaxLength != nil && utf8.RuneCountInString(text) > *schema.MaxLength {
+		return models.ValidationErrors{{
+			Field:   field,
+			Code:    models.CodeTooLong,
+			Message: fmt.Sprintf("cannot be longer than %d characters", *schema.MaxLength),
+		}}
+	}
+
+	switch schema.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, text); err != nil {
+			return invalid(field, "must be an RFC 3339 date")
+		}
+	case "email":
+		if _, err := mail.ParseAddress(text); err != nil {
+			return invalid(field, "must be an email address")
+		}
+	case "uri":
+		if parsed, err := url.Parse(text); err != nil || !parsed.IsAbs() {
+			return models.ValidationErrors{{Field: field, Code: models.CodeInvalidURL, Message: "must be an absolute url"}}
+		}
+	}
+
+	return nil
+}
+
+func validateNumber(field string, schema *Schema, text string) models.ValidationErrors {
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return invalid(field, "must be "+article(schema.Type))
+	}
+
+	if schema.Type == "integer" {
+		if _, err := strconv.ParseInt(text, 10, 64); err != nil {
+			return invalid(field, "must be an integer")
+		}
+	}
+
+	if schema.Minimum != nil && number < float64(*schema.Minimum) {
+		return invalid(field, fmt.Sprintf("must be at least %d", *schema.Minimum))
+	}
+
+	if schema.Maximum != nil && number > float64(*schema.Maximum) {
+		return invalid(field, fmt.Sprintf("must be at most %d", *schema.Maximum))
+	}
+
+	return nil
+}
+
+func invalid(field, message string) models.ValidationErrors {
+	return models.ValidationErrors{{Field: field, Code: models.CodeInvalidValue, Message: message}}
+}
+
+// join will add the name of a property to the path of its object, the properties of the body are not prefixed
+func join(field, name string) string {
+	if field == "" || field == "body" {
+		return name
+	}
+
+	return field + "." + name
+}
+
+func article(schemaType string) string {
+	if schemaType == "integer" {
+		return "an integer"
+	}
+
+	return "a " + schemaType
+}
+
+// oneOf will list the values, e.g. "a, b or c". An empty value is accepted but not listed
+func oneOf(enum []string) string {
+	var values []string
+	for _, value := range enum {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
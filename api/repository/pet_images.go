@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/jinzhu/gorm"
+)
+
+// SavePetImage will record an image of a pet and add its URL to the photo URLs of the pet in a single transaction.
+// The URL is given by photoURL once the image has an id, gorm.ErrRecordNotFound is returned when there is no pet with the id
+func (p *PetRepository) SavePetImage(image *models.PetImage, photoURL func(image *models.PetImage) string) error {
+	return p.transaction(func(tx *gorm.DB) error {
+		var pet models.Pet
+
+		// the pet is locked so that concurrent uploads do not overwrite the photo URLs of each other
+		err := tx.Set("gorm:query_option", "FOR UPDATE").First(&pet, image.PetID).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(image).Error
+		if err != nil {
+			return err
+		}
+
+		image.URL = photoURL(image)
+		return tx.Model(&pet).Update("photos_urls", append(pet.PhotosURLs, image.URL)).Error
+	})
+}
+
+// FindPetImage will find an image of a pet, gorm.ErrRecordNotFound is returned when the pet has no image with the id.
+// The image is only found while its pet exists
+func (p *PetRepository) FindPetImage(petID string, imageID string) (*models.PetImage, error) {
+	var image models.PetImage
+
+	err := p.reader().Debug().
+		Select("pet_images.*").
+		Joins("JOIN pets ON pets.id = pet_images.pet_id").
+		Where("pet_images.id = ? AND pet_images.pet_id = ?", imageID, petID).
+		First(&image).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &image, nil
+}
@@ -1,15 +1,22 @@
 package repository
 
 import (
+	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/YannHulot/petstore/api/models"
 	"github.com/jinzhu/gorm"
 )
 
+// bulkInsertBatchSize is the number of rows inserted by a single statement,
+// PostgreSQL does not accept more than 65535 parameters in a statement
+const bulkInsertBatchSize = 500
+
 // PetRepository provides access to the database
 type PetRepository struct {
 	datastore *gorm.DB
+	replicas  *models.ReplicaPool
 }
 
 // NewPetRepository creates a new PetRepository
@@ -19,8 +26,66 @@ func NewPetRepository(db *gorm.DB) PetRepository {
 	}
 }
 
+// NewPetRepositoryWithReplicas creates a new PetRepository that sends the read queries to the replicas.
+// The writes always go to the primary database
+func NewPetRepositoryWithReplicas(db *gorm.DB, replicas *models.ReplicaPool) PetRepository {
+	return PetRepository{
+		datastore: db,
+		replicas:  replicas,
+	}
+}
+
+// Primary returns a copy of the repository that sends every query to the primary database.
+// It should be used to read data that has just been written, the replicas may not have caught up yet
+func (p *PetRepository) Primary() PetRepository {
+	return NewPetRepository(p.datastore)
+}
+
+// reader returns the connection used by the read queries:
+// a healthy replica when there is one, the primary database otherwise
+func (p *PetRepository) reader() *gorm.DB {
+	replica := p.replicas.Reader()
+	if replica == nil {
+		return p.datastore
+	}
+
+	return replica
+}
+
+// Transaction will run fn with a copy of the repository that sends every query to a single transaction.
+// The transaction is committed when fn succeeds and rolled back when it returns an error, the error is returned as is
+func (p *PetRepository) Transaction(fn func(repository PetRepository) error) error {
+	return p.transaction(func(tx *gorm.DB) error {
+		return fn(NewPetRepository(tx))
+	})
+}
+
+// transaction will run fn in a new transaction, or in the current one when the repository is already bound to a transaction
+func (p *PetRepository) transaction(fn func(tx *gorm.DB) error) error {
+	if _, inTransaction := p.datastore.CommonDB().(*sql.Tx); inTransaction {
+		return fn(p.datastore)
+	}
+
+	tx := p.datastore.Debug().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	err := fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
 // SavePet will save a pet in the database
 func (p *PetRepository) SavePet(pet *models.Pet) (*models.Pet, error) {
+	// the timestamps are set by the database layer, not by the client
+	pet.CreatedAt = nil
+	pet.UpdatedAt = nil
+
 	err := p.datastore.Debug().Model(&models.Pet{}).Create(&pet).Error
 	if err != nil {
 		return &models.Pet{}, err
@@ -33,7 +98,7 @@ func (p *PetRepository) SavePet(pet *models.Pet) (*models.Pet, error) {
 func (p *PetRepository) FindPetByID(id string) (*models.Pet, error) {
 	var pet models.Pet
 
-	err := p.datastore.Debug().Preload("Tags").Preload("Category").First(&pet, id).Error
+	err := p.reader().Debug().Preload("Tags").Preload("Category").First(&pet, id).Error
 	if err != nil {
 		return &pet, err
 	}
@@ -41,15 +106,151 @@ func (p *PetRepository) FindPetByID(id string) (*models.Pet, error) {
 	return &pet, nil
 }
 
-// UpdatePetAttributes will update a pet's name and status in the database
+// SavePets will save new pets in a single transaction, the ids and the dates given by the database are set on the pets.
+// The pets, their tags and their categories are inserted with a few statements per batch of pets rather than one per row,
+// the ids of the tags and the categories are always given by the database
+func (p *PetRepository) SavePets(pets []models.Pet) error {
+	return p.transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(pets); start += bulkInsertBatchSize {
+			end := start + bulkInsertBatchSize
+			if end > len(pets) {
+				end = len(pets)
+			}
+
+			err := insertPets(tx, pets[start:end])
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// insertPets will insert a batch of pets, then their tags and their categories
+func insertPets(tx *gorm.DB, pets []models.Pet) error {
+	now := gorm.NowFunc()
+
+	rows := make([]string, len(pets))
+	var values []interface{}
+	for i := range pets {
+		pets[i].CreatedAt = &now
+		pets[i].UpdatedAt = &now
+
+		rows[i] = "(?,?,?,?,?)"
+		values = append(values, pets[i].Name, pets[i].PhotosURLs, pets[i].Status, now, now)
+	}
+
+	ids, err := insertReturningIDs(tx,
+		`INSERT INTO "pets" ("name","photos_urls","status","created_at","updated_at") VALUES `+strings.Join(rows, ","), len(pets), values)
+	if err != nil {
+		return err
+	}
+
+	// the tags and the categories are inserted in the same order as their pets
+	var tags []*models.Tag
+	var categories []*models.Category
+	rows, values = nil, nil
+	for i := range pets {
+		pets[i].ID = ids[i]
+
+		for j := range pets[i].Tags {
+			tag := &pets[i].Tags[j]
+			tag.PetID = pets[i].ID
+			tags = append(tags, tag)
+
+			rows = append(rows, "(?,?)")
+			values = append(values, tag.Name, tag.PetID)
+		}
+	}
+
+	if len(tags) > 0 {
+		ids, err = insertReturningIDs(tx, `INSERT INTO "tags" ("name","pet_id") VALUES `+strings.Join(rows, ","), len(tags), values)
+		if err != nil {
+			return err
+		}
+
+		for i, tag := range tags {
+			tag.ID = ids[i]
+		}
+	}
+
+	rows, values = nil, nil
+	for i := range pets {
+		// like gorm does, the empty categories are not saved
+		category := &pets[i].Category
+		if category.ID == 0 && category.Name == "" {
+			continue
+		}
+
+		category.PetID = pets[i].ID
+		categories = append(categories, category)
+
+		rows = append(rows, "(?,?)")
+		values = append(values, category.Name, category.PetID)
+	}
+
+	if len(categories) > 0 {
+		ids, err = insertReturningIDs(tx, `INSERT INTO "categories" ("name","pet_id") VALUES `+strings.Join(rows, ","), len(categories), values)
+		if err != nil {
+			return err
+		}
+
+		for i, category := range categories {
+			category.ID = ids[i]
+		}
+	}
+
+	return nil
+}
+
+// insertReturningIDs will run an insert of count rows and return the ids of the rows, in the order of the values
+func insertReturningIDs(tx *gorm.DB, insert string, count int, values []interface{}) ([]uint64, error) {
+	rows, err := tx.Raw(insert+` RETURNING "id"`, values...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) != count {
+		return nil, fmt.Errorf("%d rows were inserted instead of %d", len(ids), count)
+	}
+
+	return ids, nil
+}
+
+// UpdatePetAttributes will update a pet's name and status in the database, the empty values are left unchanged
 func (p *PetRepository) UpdatePetAttributes(id string, name string, status string) (*models.Pet, error) {
-	err := p.datastore.Debug().Model(&models.Pet{}).Where("id = ?", id).Updates(
-		map[string]interface{}{"name": name, "status": status}).Error
+	attributes := map[string]interface{}{}
+	if name != "" {
+		attributes["name"] = name
+	}
+	if status != "" {
+		attributes["status"] = status
+	}
+
+	err := p.datastore.Debug().Model(&models.Pet{}).Where("id = ?", id).Updates(attributes).Error
 	if err != nil {
 		return &models.Pet{}, err
 	}
 
-	pet, err := p.FindPetByID(id)
+	// read from the primary, the update may not have reached the replicas yet
+	primary := p.Primary()
+	pet, err := primary.FindPetByID(id)
 	if err != nil {
 		return &models.Pet{}, err
 	}
@@ -63,12 +264,93 @@ func (p *PetRepository) UpdatePet(updatedPet *models.Pet) (*models.Pet, error) {
 		return &models.Pet{}, fmt.Errorf("pet id is null, cannot update")
 	}
 
+	// the creation date cannot be changed, the update date is set by the database layer
+	updatedPet.CreatedAt = nil
+	updatedPet.UpdatedAt = nil
+
 	// update the main Pet record
 	err := p.datastore.Debug().Model(&models.Pet{}).Updates(&updatedPet).Error
 	if err != nil {
 		return &models.Pet{}, err
 	}
 
+	err = replacePetAssociations(p.datastore, updatedPet)
+	if err != nil {
+		return &models.Pet{}, err
+	}
+
+	return updatedPet, nil
+}
+
+// PatchPet will load the pet, change it with the patch function and save the result in a single transaction.
+// The pet is locked until the transaction ends so that concurrent patches do not overwrite each other.
+// Nothing is saved when the patch function returns an error, the error is returned as is
+func (p *PetRepository) PatchPet(id string, patch func(pet *models.Pet) error) (*models.Pet, error) {
+	var pet *models.Pet
+
+	err := p.transaction(func(tx *gorm.DB) error {
+		var err error
+		pet, err = patchPet(tx, id, patch)
+		return err
+	})
+	if err != nil {
+		return &models.Pet{}, err
+	}
+
+	return pet, nil
+}
+
+func patchPet(tx *gorm.DB, id string, patch func(pet *models.Pet) error) (*models.Pet, error) {
+	var stored models.Pet
+
+	err := tx.Set("gorm:query_option", "FOR UPDATE").First(&stored, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Model(&stored).Related(&stored.Tags).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Model(&stored).Related(&stored.Category).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+
+	patched := stored
+	err = patch(&patched)
+	if err != nil {
+		return nil, err
+	}
+
+	// a map is used so that the fields emptied by the patch are saved too
+	err = tx.Model(&models.Pet{ID: stored.ID}).Updates(map[string]interface{}{
+		"name":        patched.Name,
+		"photos_urls": patched.PhotosURLs,
+		"status":      patched.Status,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	patched.ID = stored.ID
+	err = replacePetAssociations(tx, &patched)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved models.Pet
+	err = tx.Preload("Tags").Preload("Category").First(&saved, stored.ID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &saved, nil
+}
+
+// replacePetAssociations will replace the tags and the category of the pet in the database with the ones of the payload
+func replacePetAssociations(db *gorm.DB, updatedPet *models.Pet) error {
 	// let's assume that the client sending the payload is the source of truth.
 	// if we have a Pet record in a the DB with related Tag records, then we have to compare the Tags from the payload,
 	// with the tags from the DB.
@@ -86,14 +368,14 @@ func (p *PetRepository) UpdatePet(updatedPet *models.Pet) (*models.Pet, error) {
 	// WARNING: In a production environment, WE WOULD NOT DO THIS.
 
 	// Delete all the related records
-	err = p.datastore.Debug().Where("pet_id = ?", updatedPet.ID).Delete(&models.Tag{}).Error
+	err := db.Debug().Where("pet_id = ?", updatedPet.ID).Delete(&models.Tag{}).Error
 	if err != nil {
-		return &models.Pet{}, err
+		return err
 	}
 
-	err = p.datastore.Debug().Where("pet_id = ?", updatedPet.ID).Delete(&models.Category{}).Error
+	err = db.Debug().Where("pet_id = ?", updatedPet.ID).Delete(&models.Category{}).Error
 	if err != nil && !gorm.IsRecordNotFoundError(err) {
-		return &models.Pet{}, err
+		return err
 	}
 
 	// create the new records
@@ -103,36 +385,84 @@ func (p *PetRepository) UpdatePet(updatedPet *models.Pet) (*models.Pet, error) {
 	for _, tag := range tags {
 		// set up the association with teh pet record
 		tag.PetID = updatedPet.ID
-		err := p.datastore.Debug().Model(&models.Tag{}).Save(&tag).Error
+		err := db.Debug().Model(&models.Tag{}).Save(&tag).Error
 		if err != nil {
-			return &models.Pet{}, err
+			return err
 		}
 	}
 
 	// set up the association with the pet record
 	category.PetID = updatedPet.ID
-	err = p.datastore.Debug().Model(&models.Category{}).Save(&category).Error
+	return db.Debug().Model(&models.Category{}).Save(&category).Error
+}
+
+// FindPetByStatus will find the pets that have any of the statuses, ordered by id.
+// Only the pets with an id greater than afterID are returned (keyset pagination), at most limit of them.
+// The pets are fetched in a single query and the tags and categories of all the pets
+// are loaded with one batched query per association
+func (p *PetRepository) FindPetByStatus(statuses []string, afterID uint64, limit int) (*[]models.Pet, error) {
+	var pets []models.Pet
+
+	if len(statuses) == 0 {
+		return &[]models.Pet{}, fmt.Errorf("status is empty. status is required to do the search")
+	}
+
+	if limit <= 0 {
+		return &[]models.Pet{}, fmt.Errorf("limit must be positive")
+	}
+
+	err := p.reader().Debug().
+		Model(&models.Pet{}).
+		Preload("Tags").
+		Preload("Category").
+		Where("status IN (?) AND id > ?", statuses, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&pets).Error
 	if err != nil {
-		return &models.Pet{}, err
+		return &[]models.Pet{}, err
 	}
 
-	return updatedPet, nil
+	return &pets, nil
+}
+
+// CountPetsByStatus will count the pets that have any of the statuses
+func (p *PetRepository) CountPetsByStatus(statuses []string) (int, error) {
+	var count int
+
+	err := p.reader().Debug().
+		Model(&models.Pet{}).
+		Where("status IN (?)", statuses).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
 }
 
-// FindPetByStatus will find pets by status
-func (p *PetRepository) FindPetByStatus(status string) (*[]models.Pet, error) {
+// FindPetByTags will find the pets that have any of the tags, or all of them when matchAll is true.
+// The pets are ordered by id and paginated like in FindPetByStatus
+func (p *PetRepository) FindPetByTags(tags []string, matchAll bool, afterID uint64, limit int) (*[]models.Pet, error) {
 	var pets []models.Pet
 
-	if len(status) == 0 {
-		return &[]models.Pet{}, fmt.Errorf("status is empty. status is required to do the search")
+	if len(tags) == 0 {
+		return &[]models.Pet{}, fmt.Errorf("tags are empty. at least one tag is required to do the search")
 	}
 
-	err := p.datastore.Debug().
+	if limit <= 0 {
+		return &[]models.Pet{}, fmt.Errorf("limit must be positive")
+	}
+
+	db := p.reader()
+	err := db.Debug().
 		Model(&models.Pet{}).
 		Preload("Tags").
 		Preload("Category").
-		Where("status = ?", status).
-		Limit(100).
+		Where("id IN ?", petIDsWithTags(db, tags, matchAll)).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
 		Find(&pets).Error
 	if err != nil {
 		return &[]models.Pet{}, err
@@ -141,25 +471,78 @@ func (p *PetRepository) FindPetByStatus(status string) (*[]models.Pet, error) {
 	return &pets, nil
 }
 
-// DeletePet will delete a pet in the database
-func (p *PetRepository) DeletePet(id string) error {
-	// cascading deletes
-	// try to delete the related records first and then the main record
+// CountPetsByTags will count the pets that have any of the tags, or all of them when matchAll is true
+func (p *PetRepository) CountPetsByTags(tags []string, matchAll bool) (int, error) {
+	var count int
 
-	err := p.datastore.Debug().Where("pet_id = ?", id).Delete(&models.Tag{}).Error
+	db := p.reader()
+	err := db.Debug().
+		Model(&models.Pet{}).
+		Where("id IN ?", petIDsWithTags(db, tags, matchAll)).
+		Count(&count).Error
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	err = p.datastore.Debug().Where("pet_id = ?", id).Delete(&models.Category{}).Error
-	if err != nil && !gorm.IsRecordNotFoundError(err) {
-		return err
+	return count, nil
+}
+
+// petIDsWithTags builds a sub query that selects the ids of the pets that have any of the tags,
+// or all of them when matchAll is true
+func petIDsWithTags(db *gorm.DB, tags []string, matchAll bool) interface{} {
+	// the same tag could be sent twice, it must only be counted once when all the tags have to match
+	uniqueTags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			uniqueTags = append(uniqueTags, tag)
+		}
 	}
 
-	err = p.datastore.Debug().Where("id = ?", id).Delete(&models.Pet{}).Error
-	if err != nil && !gorm.IsRecordNotFoundError(err) {
-		return err
+	query := db.Table("tags").Select("pet_id").Where("name IN (?)", uniqueTags)
+	if matchAll {
+		query = query.Group("pet_id").Having("COUNT(DISTINCT name) = ?", len(uniqueTags))
 	}
 
-	return nil
+	return query.SubQuery()
+}
+
+// DeletePet will delete a pet, its tags, its category and the records of its images in a single transaction.
+// The deleted images are returned so that their content can be removed from the storage once the transaction is committed.
+// gorm.ErrRecordNotFound is returned when there is no pet with the id
+func (p *PetRepository) DeletePet(id string) ([]models.PetImage, error) {
+	var images []models.PetImage
+
+	err := p.transaction(func(tx *gorm.DB) error {
+		err := tx.Where("pet_id = ?", id).Find(&images).Error
+		if err != nil {
+			return err
+		}
+
+		// cascading deletes
+		// try to delete the related records first and then the main record
+		for _, related := range []interface{}{&models.PetImage{}, &models.Tag{}, &models.Category{}} {
+			err = tx.Where("pet_id = ?", id).Delete(related).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		result := tx.Where("id = ?", id).Delete(&models.Pet{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return images, nil
 }
@@ -2,6 +2,9 @@ package repository
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"io/ioutil"
+	"log"
 	"regexp"
 	"testing"
 	"time"
@@ -58,8 +61,8 @@ func (s *Suite) Test_repository_UpdatePetAttributes() {
 	s.mock.ExpectBegin()
 
 	s.mock.ExpectExec(regexp.QuoteMeta(
-		`UPDATE "pets" SET "name" = $1, "status" = $2 WHERE (id = $3)`)).
-		WithArgs(name, status, id).
+		`UPDATE "pets" SET "name" = $1, "status" = $2, "updated_at" = $3 WHERE (id = $4)`)).
+		WithArgs(name, status, sqlmock.AnyArg(), id).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	s.mock.ExpectCommit()
@@ -158,8 +161,8 @@ func (s *Suite) Test_repository_FindPetByStatus() {
 	)
 
 	s.mock.ExpectQuery(regexp.QuoteMeta(
-		`SELECT * FROM "pets" WHERE (status = $1) LIMIT 100`)).
-		WithArgs(status).
+		`SELECT * FROM "pets" WHERE (status IN ($1) AND id > $2) ORDER BY id ASC LIMIT 101`)).
+		WithArgs(status, 0).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).
 			AddRow(id, name, status))
 
@@ -175,7 +178,7 @@ func (s *Suite) Test_repository_FindPetByStatus() {
 		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).
 			AddRow(1, "mock-category-name", 4))
 
-	res, err := s.repository.FindPetByStatus(status)
+	res, err := s.repository.FindPetByStatus([]string{status}, 0, 101)
 
 	require.NoError(s.T(), err)
 
@@ -237,8 +240,8 @@ func (s *Suite) Test_repository_SavePet() {
 	s.mock.ExpectBegin()
 
 	s.mock.ExpectQuery(regexp.QuoteMeta(
-		`INSERT INTO "pets" ("name","photos_urls","status") VALUES ($1,$2,$3) RETURNING "pets"."id"`)).
-		WithArgs(name, urls, status).
+		`INSERT INTO "pets" ("name","photos_urls","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5) RETURNING "pets"."id"`)).
+		WithArgs(name, urls, status, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "categories" SET "name" = $1, "pet_id" = $2  WHERE "categories"."id" = $3`)).
@@ -255,6 +258,10 @@ func (s *Suite) Test_repository_SavePet() {
 
 	require.NoError(s.T(), err)
 
+	// the timestamps are set when the pet is saved
+	require.NotNil(s.T(), res.CreatedAt)
+	require.NotNil(s.T(), res.UpdatedAt)
+
 	// value returned from the DB
 	expectedCategory.ID = 2
 	expectedTag.ID = 5
@@ -266,6 +273,8 @@ func (s *Suite) Test_repository_SavePet() {
 		Tags:       []models.Tag{expectedTag},
 		PhotosURLs: urls,
 		Status:     status,
+		CreatedAt:  res.CreatedAt,
+		UpdatedAt:  res.UpdatedAt,
 	},
 		res))
 }
@@ -303,8 +312,8 @@ func (s *Suite) Test_repository_UpdatePet() {
 
 	s.mock.ExpectBegin()
 	s.mock.ExpectExec(regexp.QuoteMeta(
-		`UPDATE "pets" SET "id" = $1, "name" = $2, "photos_urls" = $3, "status" = $4  WHERE "pets"."id" = $5`)).
-		WithArgs(2, name, urls, status, 2).
+		`UPDATE "pets" SET "id" = $1, "name" = $2, "photos_urls" = $3, "status" = $4, "updated_at" = $5  WHERE "pets"."id" = $6`)).
+		WithArgs(2, name, urls, status, sqlmock.AnyArg(), 2).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	s.mock.ExpectExec(regexp.QuoteMeta(`UPDATE "categories" SET "name" = $1, "pet_id" = $2  WHERE "categories"."id" = $3`)).
@@ -356,32 +365,498 @@ func (s *Suite) Test_repository_UpdatePet() {
 		res))
 }
 
+// expectPetDeleted expects the queries of DeletePet, in the transaction that it runs in
+func expectPetDeleted(mock sqlmock.Sqlmock, id string, images *sqlmock.Rows, rows int64) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "pet_images" WHERE (pet_id = $1)`)).
+		WithArgs(id).
+		WillReturnRows(images)
+	for _, table := range []string{"pet_images", "tags", "categories"} {
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "` + table + `" WHERE (pet_id = $1)`)).
+			WithArgs(id).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "pets" WHERE (id = $1)`)).
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, rows))
+}
+
 func (s *Suite) Test_repository_DeletePet() {
-	var (
-		id = "2"
-	)
+	id := "2"
 
+	// the pet and everything that refers to it are deleted in a single transaction
 	s.mock.ExpectBegin()
-	s.mock.ExpectExec(regexp.QuoteMeta(
-		`DELETE FROM "tags" WHERE (pet_id = $1)`)).
-		WithArgs(id).WillDelayFor(time.Second).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectPetDeleted(s.mock, id, sqlmock.NewRows([]string{"id", "pet_id", "key"}).AddRow(7, 2, "pets/2/abc"), 1)
 	s.mock.ExpectCommit()
 
+	images, err := s.repository.DeletePet(id)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []models.PetImage{{ID: 7, PetID: 2, Key: "pets/2/abc"}}, images)
+}
+
+func (s *Suite) Test_repository_DeletePet_notFound() {
+	id := "3"
+
 	s.mock.ExpectBegin()
-	s.mock.ExpectExec(regexp.QuoteMeta(
-		`DELETE FROM "categories" WHERE (pet_id = $1)`)).
-		WithArgs(id).WillDelayFor(time.Second).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectPetDeleted(s.mock, id, sqlmock.NewRows([]string{"id"}), 0)
+	s.mock.ExpectRollback()
+
+	_, err := s.repository.DeletePet(id)
+	require.True(s.T(), gorm.IsRecordNotFoundError(err))
+}
+
+func (s *Suite) Test_repository_SavePets() {
+	pets := []models.Pet{
+		{Name: "rex", Status: "available", Tags: []models.Tag{{Name: "small"}, {Name: "cute"}}, Category: models.Category{Name: "dogs"}},
+		{Name: "tom", PhotosURLs: pq.StringArray{"https://example.com/tom.png"}},
+	}
+
+	s.mock.ExpectBegin()
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO "pets" ("name","photos_urls","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5),($6,$7,$8,$9,$10) RETURNING "id"`)).
+		WithArgs("rex", nil, "available", sqlmock.AnyArg(), sqlmock.AnyArg(),
+			"tom", "{\"https://example.com/tom.png\"}", "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7).AddRow(8))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "tags" ("name","pet_id") VALUES ($1,$2),($3,$4) RETURNING "id"`)).
+		WithArgs("small", 7, "cute", 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(20).AddRow(21))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "categories" ("name","pet_id") VALUES ($1,$2) RETURNING "id"`)).
+		WithArgs("dogs", 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(30))
+
 	s.mock.ExpectCommit()
 
+	err := s.repository.SavePets(pets)
+	require.NoError(s.T(), err)
+
+	require.Equal(s.T(), uint64(7), pets[0].ID)
+	require.Equal(s.T(), uint64(8), pets[1].ID)
+	require.Equal(s.T(), []models.Tag{{ID: 20, Name: "small", PetID: 7}, {ID: 21, Name: "cute", PetID: 7}}, pets[0].Tags)
+	require.Equal(s.T(), models.Category{ID: 30, Name: "dogs", PetID: 7}, pets[0].Category)
+	require.Equal(s.T(), models.Category{}, pets[1].Category)
+	require.NotNil(s.T(), pets[1].CreatedAt)
+}
+
+func (s *Suite) Test_repository_SavePets_batches() {
+	pets := make([]models.Pet, bulkInsertBatchSize+1)
+	for i := range pets {
+		pets[i].Name = "pet"
+	}
+
+	firstBatch := sqlmock.NewRows([]string{"id"})
+	for i := 1; i <= bulkInsertBatchSize; i++ {
+		firstBatch.AddRow(i)
+	}
+
 	s.mock.ExpectBegin()
-	s.mock.ExpectExec(regexp.QuoteMeta(
-		`DELETE FROM "pets" WHERE (id = $1)`)).
-		WithArgs(id).WillDelayFor(time.Second).
+	s.mock.ExpectQuery(`INSERT INTO "pets" .* VALUES (\(\$\d+,\$\d+,\$\d+,\$\d+,\$\d+\),){499}\(.*\) RETURNING "id"`).
+		WillReturnRows(firstBatch)
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`INSERT INTO "pets" ("name","photos_urls","status","created_at","updated_at") VALUES ($1,$2,$3,$4,$5) RETURNING "id"`)).
+		WillReturnError(sql.ErrConnDone)
+	s.mock.ExpectRollback()
+
+	// nothing is saved when a batch fails
+	err := s.repository.SavePets(pets)
+	require.Equal(s.T(), sql.ErrConnDone, err)
+}
+
+func (s *Suite) Test_repository_Transaction() {
+	s.mock.ExpectBegin()
+	expectPetDeleted(s.mock, "3", sqlmock.NewRows([]string{"id"}), 0)
+	s.mock.ExpectRollback()
+
+	// the queries of the repository given to the function share a single transaction
+	err := s.repository.Transaction(func(repository PetRepository) error {
+		_, err := repository.DeletePet("3")
+		return err
+	})
+	require.True(s.T(), gorm.IsRecordNotFoundError(err))
+}
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	require.NoError(t, err)
+
+	return gormDB, mock
+}
+
+func expectFindPetByID(mock sqlmock.Sqlmock, id string) {
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE ("pets"."id" = $1) ORDER BY "pets"."id" ASC LIMIT 1`)).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(id, "doggy"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "tags" WHERE ("pet_id" IN ($1)) ORDER BY "tags"."id" ASC`)).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "categories" WHERE ("pet_id" IN ($1)) ORDER BY "categories"."id" ASC`)).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+}
+
+func TestReplicaRouting(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	replicas := models.NewReplicaPool(replica)
+	petRepository := NewPetRepositoryWithReplicas(primary, replicas)
+
+	// the reads go to the replica
+	expectFindPetByID(replicaMock, "1")
+	_, err := petRepository.FindPetByID("1")
+	require.NoError(t, err)
+
+	// a write and the read that follows it stay on the primary
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectExec(regexp.QuoteMeta(
+		`UPDATE "pets" SET "name" = $1, "status" = $2, "updated_at" = $3 WHERE (id = $4)`)).
+		WithArgs("doggy", "sold", sqlmock.AnyArg(), "1").
 		WillReturnResult(sqlmock.NewResult(1, 1))
-	s.mock.ExpectCommit()
+	primaryMock.ExpectCommit()
+	expectFindPetByID(primaryMock, "1")
+
+	_, err = petRepository.UpdatePetAttributes("1", "doggy", "sold")
+	require.NoError(t, err)
+
+	// the primary is used when no replica is healthy
+	replicaMock.ExpectClose()
+	require.NoError(t, replica.DB().Close())
+	replicas.CheckHealth()
+
+	expectFindPetByID(primaryMock, "1")
+	_, err = petRepository.FindPetByID("1")
+	require.NoError(t, err)
+
+	require.NoError(t, primaryMock.ExpectationsWereMet())
+	require.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func (s *Suite) Test_repository_FindPetByTags() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE (id IN (SELECT pet_id FROM "tags" WHERE (name IN ($1,$2)))) AND (id > $3) ORDER BY id ASC LIMIT 10`)).
+		WithArgs("small", "cute", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).
+			AddRow(1, "doggy", "available"))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tags" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).
+			AddRow(1, "small", 2))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).
+			AddRow(1, "dogs", 4))
 
-	err := s.repository.DeletePet(id)
+	res, err := s.repository.FindPetByTags([]string{"small", "cute", "small"}, false, 0, 10)
 	require.NoError(s.T(), err)
+
+	require.Nil(s.T(), deep.Equal(&[]models.Pet{{
+		ID:       1,
+		Name:     "doggy",
+		Status:   "available",
+		Category: models.Category{ID: 4, Name: "dogs", PetID: 1},
+		Tags:     []models.Tag{{ID: 2, Name: "small", PetID: 1}},
+	}}, res))
+}
+
+func (s *Suite) Test_repository_FindPetByTags_matchAll() {
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE (id IN (SELECT pet_id FROM "tags" WHERE (name IN ($1,$2)) GROUP BY pet_id HAVING (COUNT(DISTINCT name) = $3))) AND (id > $4) ORDER BY id ASC LIMIT 10`)).
+		WithArgs("small", "cute", 2, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}))
+
+	res, err := s.repository.FindPetByTags([]string{"small", "cute"}, true, 5, 10)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), *res, 0)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT count(*) FROM "pets" WHERE (id IN (SELECT pet_id FROM "tags" WHERE (name IN ($1,$2)) GROUP BY pet_id HAVING (COUNT(DISTINCT name) = $3)))`)).
+		WithArgs("small", "cute", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	count, err := s.repository.CountPetsByTags([]string{"small", "cute"}, true)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 3, count)
+
+	_, err = s.repository.FindPetByTags([]string{}, true, 0, 10)
+	require.Error(s.T(), err)
+}
+
+// simulatedRoundTrip is the latency added to every query in the benchmarks,
+// it makes the cost of the round trips to the database visible
+const simulatedRoundTrip = 200 * time.Microsecond
+
+func newBenchmarkRepository(b *testing.B) (PetRepository, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(b, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	require.NoError(b, err)
+
+	// the queries are logged by the repository, keep the benchmark output readable
+	gormDB.SetLogger(gorm.Logger{LogWriter: log.New(ioutil.Discard, "", 0)})
+
+	return NewPetRepository(gormDB), mock
+}
+
+// expectFindPetByStatus registers the queries sent to find the pets with the statuses:
+// the pets themselves, then their tags and categories
+func expectFindPetByStatus(mock sqlmock.Sqlmock, statuses []string, petsPerStatus int) {
+	pets := sqlmock.NewRows([]string{"id", "name", "status"})
+	tags := sqlmock.NewRows([]string{"pet_id", "name", "id"})
+	categories := sqlmock.NewRows([]string{"pet_id", "name", "id"})
+
+	id := 0
+	for _, status := range statuses {
+		for i := 0; i < petsPerStatus; i++ {
+			id++
+			pets.AddRow(id, "doggy", status)
+			tags.AddRow(id, "small", id)
+			categories.AddRow(id, "dogs", id)
+		}
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "pets"`).WillDelayFor(simulatedRoundTrip).WillReturnRows(pets)
+	mock.ExpectQuery(`SELECT \* FROM "tags"`).WillDelayFor(simulatedRoundTrip).WillReturnRows(tags)
+	mock.ExpectQuery(`SELECT \* FROM "categories"`).WillDelayFor(simulatedRoundTrip).WillReturnRows(categories)
+}
+
+func BenchmarkFindPetByStatus(b *testing.B) {
+	statuses := []string{"available", "pending", "sold"}
+	petsPerStatus := 30
+
+	// this is how the controller used to search: one query for the pets, one for the tags
+	// and one for the categories for every status
+	b.Run("one query per status", func(b *testing.B) {
+		petRepository, mock := newBenchmarkRepository(b)
+
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			for _, status := range statuses {
+				expectFindPetByStatus(mock, []string{status}, petsPerStatus)
+			}
+			b.StartTimer()
+
+			for _, status := range statuses {
+				_, err := petRepository.FindPetByStatus([]string{status}, 0, 100)
+				require.NoError(b, err)
+			}
+		}
+
+		// every expected query was sent, 3 per status
+		require.NoError(b, mock.ExpectationsWereMet())
+	})
+
+	b.Run("single query", func(b *testing.B) {
+		petRepository, mock := newBenchmarkRepository(b)
+
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			expectFindPetByStatus(mock, statuses, petsPerStatus)
+			b.StartTimer()
+
+			_, err := petRepository.FindPetByStatus(statuses, 0, 100)
+			require.NoError(b, err)
+		}
+
+		// every expected query was sent, 3 for all the statuses
+		require.NoError(b, mock.ExpectationsWereMet())
+	})
+}
+
+func (s *Suite) Test_repository_SearchPets() {
+	createdAfter := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	search := models.PetSearch{
+		Name:         "50%_Rex",
+		Category:     "dogs",
+		Tags:         []string{"small"},
+		Statuses:     []string{"available", "pending"},
+		CreatedAfter: &createdAfter,
+		Sort:         []models.SortField{{Field: "updatedAt", Descending: true}, {Field: "name"}},
+		Limit:        10,
+		Offset:       20,
+	}
+
+	filters := `WHERE (LOWER(name) LIKE $1 ESCAPE '\') ` +
+		`AND (id IN (SELECT pet_id FROM "categories" WHERE (name = $2))) ` +
+		`AND (id IN (SELECT pet_id FROM "tags" WHERE (name IN ($3)))) ` +
+		`AND (status IN ($4,$5)) ` +
+		`AND (created_at >= $6)`
+	args := []driver.Value{`%50\%\_rex%`, "dogs", "small", "available", "pending", createdAfter}
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "pets" ` + filters)).
+		WithArgs(args...).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(21))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" ` + filters + ` ORDER BY updated_at DESC,name ASC,id ASC LIMIT 10 OFFSET 20`)).
+		WithArgs(args...).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).
+			AddRow(3, "50%_Rex", "available"))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tags" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).AddRow(3, "small", 1))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).AddRow(3, "dogs", 2))
+
+	res, err := s.repository.SearchPets(search)
+	require.NoError(s.T(), err)
+
+	require.Nil(s.T(), deep.Equal(&models.PetSearchResult{
+		Total: 21,
+		Pets: []models.Pet{{
+			ID:       3,
+			Name:     "50%_Rex",
+			Status:   "available",
+			Category: models.Category{ID: 2, Name: "dogs", PetID: 3},
+			Tags:     []models.Tag{{ID: 1, Name: "small", PetID: 3}},
+		}},
+	}, res))
+}
+
+func (s *Suite) Test_repository_SearchPets_invalidSort() {
+	_, err := s.repository.SearchPets(models.PetSearch{
+		Sort:  []models.SortField{{Field: "name; DROP TABLE pets"}},
+		Limit: 10,
+	})
+	require.EqualError(s.T(), err, `cannot sort by "name; DROP TABLE pets"`)
+}
+
+func (s *Suite) Test_repository_SearchPets_fullText() {
+	search := models.PetSearch{Query: "fluffy cat", Limit: 10}
+
+	s.mock.ExpectQuery(`SELECT count\(\*\) FROM "pets" WHERE \(to_tsvector\('english', .+\) @@ plainto_tsquery\('english', \$1\)\)`).
+		WithArgs("fluffy cat").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	s.mock.ExpectQuery(`SELECT \* FROM "pets" WHERE \(to_tsvector\('english', .+\) @@ plainto_tsquery\('english', \$1\)\) `+
+		`ORDER BY ts_rank\(to_tsvector\('english', .+\), plainto_tsquery\('english', \$2\)\) DESC,id ASC LIMIT 10`).
+		WithArgs("fluffy cat", "fluffy cat").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).AddRow(3, "Fluffy", "available"))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tags" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).AddRow(3, "small", 1))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE ("pet_id" IN ($1))`)).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).AddRow(3, "cats", 2))
+
+	s.mock.ExpectQuery(`SELECT pets.id, ts_rank\(.+\) AS rank, ts_headline\(.+\) AS name, .+ AS category, ARRAY\(.+\) AS tags FROM pets WHERE pets.id IN \(\$5\)`).
+		WithArgs("fluffy cat", "fluffy cat", "fluffy cat", "fluffy cat", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "rank", "name", "category", "tags"}).
+			AddRow(3, 0.5, highlightStart+"Fluffy"+highlightStop+" & <b>", highlightStart+"cats"+highlightStop, "{small}"))
+
+	res, err := s.repository.SearchPets(search)
+	require.NoError(s.T(), err)
+
+	require.Equal(s.T(), 1, res.Total)
+	require.Len(s.T(), res.Pets, 1)
+	require.Nil(s.T(), deep.Equal([]models.PetHighlight{{
+		PetID:    3,
+		Rank:     0.5,
+		Name:     "<mark>Fluffy</mark> &amp; &lt;b&gt;",
+		Category: "<mark>cats</mark>",
+	}}, res.Highlights))
+}
+
+func TestSearchPets_substringFallback(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	// a dialect without full text search, like the ones used for in-memory databases
+	gormDB, err := gorm.Open("sqlite3", db)
+	require.NoError(t, err)
+
+	petRepository := NewPetRepository(gormDB)
+
+	match := `(LOWER(name) LIKE ? ESCAPE '\' OR id IN (SELECT pet_id FROM "categories" WHERE (LOWER(name) LIKE ? ESCAPE '\')) ` +
+		`OR id IN (SELECT pet_id FROM "tags" WHERE (LOWER(name) LIKE ? ESCAPE '\')))`
+	term := `WHERE ` + match + ` AND ` + match
+	args := []driver.Value{"%fluffy%", "%fluffy%", "%fluffy%", "%cat%", "%cat%", "%cat%"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "pets" ` + term)).
+		WithArgs(args...).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	// the pets with the most words found come first, a point per word in the name, the category and each tag
+	rank := `(CASE WHEN LOWER(pets.name) LIKE ? ESCAPE '\' THEN 1 ELSE 0 END) + ` +
+		`(SELECT COUNT(*) FROM categories WHERE categories.pet_id = pets.id AND LOWER(categories.name) LIKE ? ESCAPE '\') + ` +
+		`(SELECT COUNT(*) FROM tags WHERE tags.pet_id = pets.id AND LOWER(tags.name) LIKE ? ESCAPE '\')`
+	order := `ORDER BY (` + rank + ` + ` + rank + `) DESC,id ASC LIMIT 10`
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "pets" ` + term + ` ` + order)).
+		WithArgs(append(args, args...)...).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).AddRow(3, "Fluffy", "available"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "tags" WHERE ("pet_id" IN (?))`)).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).
+			AddRow(3, "small", 1).
+			AddRow(3, "<cat>-friendly", 2))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE ("pet_id" IN (?))`)).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).AddRow(3, "Cats", 2))
+
+	res, err := petRepository.SearchPets(models.PetSearch{Query: "Fluffy cat", Limit: 10})
+	require.NoError(t, err)
+
+	require.Nil(t, deep.Equal([]models.PetHighlight{{
+		PetID:    3,
+		Rank:     3,
+		Name:     "<mark>Fluffy</mark>",
+		Category: "<mark>Cat</mark>s",
+		Tags:     []string{"&lt;<mark>cat</mark>&gt;-friendly"},
+	}}, res.Highlights))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func (s *Suite) Test_repository_SearchPets_facets() {
+	search := models.PetSearch{Statuses: []string{"available", "sold"}, Limit: 10, Facets: true}
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "pets" WHERE (status IN ($1,$2))`)).
+		WithArgs("available", "sold").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT status AS value, COUNT(*) AS count FROM "pets" WHERE (status IN ($1,$2)) GROUP BY status ORDER BY count DESC, value ASC`)).
+		WithArgs("available", "sold").
+		WillReturnRows(sqlmock.NewRows([]string{"value", "count"}).
+			AddRow("available", 12).
+			AddRow("sold", 3))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT name AS value, COUNT(DISTINCT pet_id) AS count FROM "categories" `+
+			`WHERE (pet_id IN (SELECT id FROM "pets" WHERE (status IN ($1,$2)))) GROUP BY name ORDER BY count DESC, value ASC LIMIT 50`)).
+		WithArgs("available", "sold").
+		WillReturnRows(sqlmock.NewRows([]string{"value", "count"}).AddRow("dogs", 15))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT name AS value, COUNT(DISTINCT pet_id) AS count FROM "tags" `+
+			`WHERE (pet_id IN (SELECT id FROM "pets" WHERE (status IN ($1,$2)))) GROUP BY name ORDER BY count DESC, value ASC LIMIT 50`)).
+		WithArgs("available", "sold").
+		WillReturnRows(sqlmock.NewRows([]string{"value", "count"}).
+			AddRow("small", 7).
+			AddRow("cute", 2))
+
+	res, err := s.repository.SearchPets(search)
+	require.NoError(s.T(), err)
+
+	require.Nil(s.T(), deep.Equal(&models.PetFacets{
+		Statuses:   []models.FacetCount{{Value: "available", Count: 12}, {Value: "sold", Count: 3}},
+		Categories: []models.FacetCount{{Value: "dogs", Count: 15}},
+		Tags:       []models.FacetCount{{Value: "small", Count: 7}, {Value: "cute", Count: 2}},
+	}, res.Facets))
 }
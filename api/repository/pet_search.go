@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// petSortColumns maps the sort fields of the API to the columns of the pets table.
+// Only these columns can end up in the ORDER BY clause
+var petSortColumns = map[string]string{
+	"id":        "id",
+	"name":      "name",
+	"status":    "status",
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern so that they are matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likeEscape declares the escape character used by likeEscaper, not every database uses it by default
+const likeEscape = `ESCAPE '\'`
+
+const (
+	// petDocument is the text searched by the PostgreSQL full text search:
+	// the name of the pet, the name of its category and the names of its tags
+	petDocument = `to_tsvector('english', coalesce(pets.name, '') || ' ' ||
+		coalesce((SELECT string_agg(categories.name, ' ') FROM categories WHERE categories.pet_id = pets.id), '') || ' ' ||
+		coalesce((SELECT string_agg(tags.name, ' ') FROM tags WHERE tags.pet_id = pets.id), ''))`
+
+	// petTextQuery turns the words typed by the user into a query, every word has to match
+	petTextQuery = `plainto_tsquery('english', ?)`
+
+	// headlineOptions wraps the matches in the highlight markers and keeps the whole text instead of fragments
+	headlineOptions = `'StartSel=` + highlightStart + `, StopSel=` + highlightStop + `, HighlightAll=true'`
+
+	// maxFacetValues is the maximum number of categories and tags returned in the facets
+	maxFacetValues = 50
+
+	// the matches are wrapped in characters of the private use area while the text is raw,
+	// they become <mark></mark> once the text is escaped for HTML
+	highlightStart = "\ue000"
+	highlightStop  = "\ue001"
+)
+
+// SearchPets will find the pets matching the filters of the search, sorted and paginated.
+// The total number of matches is returned with the page of pets.
+// The full text search uses PostgreSQL text search, the other databases fall back to substring matching
+func (p *PetRepository) SearchPets(search models.PetSearch) (*models.PetSearchResult, error) {
+	err := search.Validate()
+	if err != nil {
+		return &models.PetSearchResult{}, err
+	}
+
+	db := p.reader()
+	fullText := search.Query != "" && db.Dialect().GetName() == "postgres"
+	result := models.PetSearchResult{Pets: []models.Pet{}}
+
+	err = applyPetSearchFilters(db.Debug().Model(&models.Pet{}), db, search, fullText).
+		Count(&result.Total).Error
+	if err != nil {
+		return &models.PetSearchResult{}, err
+	}
+
+	if search.Facets {
+		result.Facets, err = petSearchFacets(db, search, fullText)
+		if err != nil {
+			return &models.PetSearchResult{}, err
+		}
+	}
+
+	if result.Total == 0 {
+		return &result, nil
+	}
+
+	query := applyPetSearchFilters(db.Debug().Model(&models.Pet{}), db, search, fullText).
+		Preload("Tags").
+		Preload("Category")
+
+	for _, field := range search.Sort {
+		column, ok := petSortColumns[field.Field]
+		if !ok {
+			return &models.PetSearchResult{}, fmt.Errorf("cannot sort by %q", field.Field)
+		}
+
+		if field.Descending {
+			query = query.Order(column + " DESC")
+		} else {
+			query = query.Order(column + " ASC")
+		}
+	}
+
+	// the best matches come first unless the client asked for another order
+	if search.Query != "" && len(search.Sort) == 0 {
+		if fullText {
+			query = query.Order(gorm.Expr("ts_rank("+petDocument+", "+petTextQuery+") DESC", search.Query))
+		} else {
+			rank, args := substringRank(search.Query)
+			query = query.Order(gorm.Expr(rank+" DESC", args...))
+		}
+	}
+
+	// the id makes the order stable when the other fields have the same values
+	err = query.Order("id ASC").
+		Limit(search.Limit).
+		Offset(search.Offset).
+		Find(&result.Pets).Error
+	if err != nil {
+		return &models.PetSearchResult{}, err
+	}
+
+	if search.Query == "" {
+		return &result, nil
+	}
+
+	if fullText {
+		result.Highlights, err = fullTextHighlights(db, search.Query, result.Pets)
+		if err != nil {
+			return &models.PetSearchResult{}, err
+		}
+	} else {
+		result.Highlights = substringHighlights(search.Query, result.Pets)
+	}
+
+	return &result, nil
+}
+
+// applyPetSearchFilters adds the conditions of the search to the query, every value is sent as a parameter
+func applyPetSearchFilters(query *gorm.DB, db *gorm.DB, search models.PetSearch, fullText bool) *gorm.DB {
+	if search.Query != "" {
+		if fullText {
+			query = query.Where(petDocument+" @@ "+petTextQuery, search.Query)
+		} else {
+			// every word has to be found in the name, the category or the tags
+			for _, term := range strings.Fields(strings.ToLower(search.Query)) {
+				pattern := "%" + likeEscaper.Replace(term) + "%"
+				query = query.Where("LOWER(name) LIKE ? "+likeEscape+" OR id IN ? OR id IN ?",
+					pattern,
+					db.Table("categories").Select("pet_id").Where("LOWER(name) LIKE ? "+likeEscape, pattern).SubQuery(),
+					db.Table("tags").Select("pet_id").Where("LOWER(name) LIKE ? "+likeEscape, pattern).SubQuery())
+			}
+		}
+	}
+
+	if search.Name != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(search.Name)) + "%"
+		query = query.Where("LOWER(name) LIKE ? "+likeEscape, pattern)
+	}
+
+	if search.Category != "" {
+		query = query.Where("id IN ?",
+			db.Table("categories").Select("pet_id").Where("name = ?", search.Category).SubQuery())
+	}
+
+	if len(search.Tags) > 0 {
+		query = query.Where("id IN ?", petIDsWithTags(db, search.Tags, false))
+	}
+
+	if len(search.Statuses) > 0 {
+		query = query.Where("status IN (?)", search.Statuses)
+	}
+
+	if search.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *search.CreatedAfter)
+	}
+
+	if search.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *search.CreatedBefore)
+	}
+
+	if search.UpdatedAfter != nil {
+		query = query.Where("updated_at >= ?", *search.UpdatedAfter)
+	}
+
+	if search.UpdatedBefore != nil {
+		query = query.Where("updated_at < ?", *search.UpdatedBefore)
+	}
+
+	return query
+}
+
+// petSearchFacets will count the pets matching the search per status, per category and per tag.
+// The counts use the same filters as the search itself
+func petSearchFacets(db *gorm.DB, search models.PetSearch, fullText bool) (*models.PetFacets, error) {
+	facets := models.PetFacets{
+		Statuses:   []models.FacetCount{},
+		Categories: []models.FacetCount{},
+		Tags:       []models.FacetCount{},
+	}
+
+	err := applyPetSearchFilters(db.Debug().Model(&models.Pet{}), db, search, fullText).
+		Select("status AS value, COUNT(*) AS count").
+		Group("status").
+		Order("count DESC, value ASC").
+		Scan(&facets.Statuses).Error
+	if err != nil {
+		return nil, err
+	}
+
+	matchingIDs := applyPetSearchFilters(db.Model(&models.Pet{}).Select("id"), db, search, fullText).SubQuery()
+
+	associations := []struct {
+		table  string
+		target *[]models.FacetCount
+	}{
+		{table: "categories", target: &facets.Categories},
+		{table: "tags", target: &facets.Tags},
+	}
+
+	for _, association := range associations {
+		err = db.Debug().Table(association.table).
+			Select("name AS value, COUNT(DISTINCT pet_id) AS count").
+			Where("pet_id IN ?", matchingIDs).
+			Group("name").
+			Order("count DESC, value ASC").
+			Limit(maxFacetValues).
+			Scan(association.target).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &facets, nil
+}
+
+// highlightRow is a row returned by the highlight query of the full text search
+type highlightRow struct {
+	ID       uint64
+	Rank     float64
+	Name     string
+	Category string
+	Tags     pq.StringArray
+}
+
+// fullTextHighlights will ask PostgreSQL to rank the pets and to highlight the matches
+func fullTextHighlights(db *gorm.DB, text string, pets []models.Pet) ([]models.PetHighlight, error) {
+	var rows []highlightRow
+
+	ids := make([]uint64, len(pets))
+	for i, pet := range pets {
+		ids[i] = pet.ID
+	}
+
+	headline := func(column string) string {
+		return "ts_headline('english', " + column + ", " + petTextQuery + ", " + headlineOptions + ")"
+	}
+
+	err := db.Debug().Raw(
+		"SELECT pets.id, ts_rank("+petDocument+", "+petTextQuery+") AS rank, "+
+			headline("pets.name")+" AS name, "+
+			headline("coalesce((SELECT categories.name FROM categories WHERE categories.pet_id = pets.id LIMIT 1), '')")+" AS category, "+
+			"ARRAY(SELECT "+headline("tags.name")+" FROM tags WHERE tags.pet_id = pets.id ORDER BY tags.id) AS tags "+
+			"FROM pets WHERE pets.id IN (?)",
+		text, text, text, text, ids).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rowsByID := map[uint64]highlightRow{}
+	for _, row := range rows {
+		rowsByID[row.ID] = row
+	}
+
+	highlights := make([]models.PetHighlight, 0, len(pets))
+	for _, pet := range pets {
+		row := rowsByID[pet.ID]
+
+		highlight := models.PetHighlight{PetID: pet.ID, Rank: row.Rank, Name: highlightHTML(row.Name)}
+		if strings.Contains(row.Category, highlightStart) {
+			highlight.Category = highlightHTML(row.Category)
+		}
+
+		for _, tag := range row.Tags {
+			if strings.Contains(tag, highlightStart) {
+				highlight.Tags = append(highlight.Tags, highlightHTML(tag))
+			}
+		}
+
+		highlights = append(highlights, highlight)
+	}
+
+	return highlights, nil
+}
+
+// substringRank will build the rank of the substring matching: a point for every word of the search
+// found in the name, in the category and in each tag. The pets are sorted by it and the highlights report it
+func substringRank(text string) (string, []interface{}) {
+	var points []string
+	var args []interface{}
+	for _, term := range strings.Fields(strings.ToLower(text)) {
+		pattern := "%" + likeEscaper.Replace(term) + "%"
+		points = append(points,
+			"(CASE WHEN LOWER(pets.name) LIKE ? "+likeEscape+" THEN 1 ELSE 0 END)",
+			"(SELECT COUNT(*) FROM categories WHERE categories.pet_id = pets.id AND LOWER(categories.name) LIKE ? "+likeEscape+")",
+			"(SELECT COUNT(*) FROM tags WHERE tags.pet_id = pets.id AND LOWER(tags.name) LIKE ? "+likeEscape+")")
+		args = append(args, pattern, pattern, pattern)
+	}
+
+	return "(" + strings.Join(points, " + ") + ")", args
+}
+
+// substringHighlights will highlight the words of the search in the pets and rank them like substringRank.
+// It is used by the databases that do not support the full text search
+func substringHighlights(text string, pets []models.Pet) []models.PetHighlight {
+	var quotedTerms []string
+	for _, term := range strings.Fields(text) {
+		quotedTerms = append(quotedTerms, regexp.QuoteMeta(term))
+	}
+	lowerTerms := strings.Fields(strings.ToLower(text))
+
+	terms := regexp.MustCompile("(?i)(" + strings.Join(quotedTerms, "|") + ")")
+	highlight := func(value string) (string, int) {
+		matches := 0
+		for _, term := range lowerTerms {
+			if strings.Contains(strings.ToLower(value), term) {
+				matches++
+			}
+		}
+		return highlightHTML(terms.ReplaceAllString(value, highlightStart+"$0"+highlightStop)), matches
+	}
+
+	highlights := make([]models.PetHighlight, 0, len(pets))
+	for _, pet := range pets {
+		name, matches := highlight(pet.Name)
+		petHighlight := models.PetHighlight{PetID: pet.ID, Name: name, Rank: float64(matches)}
+
+		category, matches := highlight(pet.Category.Name)
+		if matches > 0 {
+			petHighlight.Category = category
+			petHighlight.Rank += float64(matches)
+		}
+
+		for _, tag := range pet.Tags {
+			tagName, matches := highlight(tag.Name)
+			if matches > 0 {
+				petHighlight.Tags = append(petHighlight.Tags, tagName)
+				petHighlight.Rank += float64(matches)
+			}
+		}
+
+		highlights = append(highlights, petHighlight)
+	}
+
+	return highlights
+}
+
+// highlightHTML will escape a highlighted value for HTML and wrap its matches in <mark></mark>
+func highlightHTML(value string) string {
+	value = html.EscapeString(value)
+	value = strings.Replace(value, highlightStart, "<mark>", -1)
+	return strings.Replace(value, highlightStop, "</mark>", -1)
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/jinzhu/gorm"
+)
+
+// SavedSearchRepository provides access to the saved searches in the database
+type SavedSearchRepository struct {
+	datastore *gorm.DB
+}
+
+// NewSavedSearchRepository creates a new SavedSearchRepository
+func NewSavedSearchRepository(db *gorm.DB) SavedSearchRepository {
+	return SavedSearchRepository{
+		datastore: db,
+	}
+}
+
+// SaveSearch will save a search in the database
+func (s *SavedSearchRepository) SaveSearch(search *models.SavedSearch) (*models.SavedSearch, error) {
+	err := s.datastore.Debug().Create(search).Error
+	if err != nil {
+		return &models.SavedSearch{}, err
+	}
+
+	return search, nil
+}
+
+// FindSavedSearchByID will find a single saved search of the owner in the DB by its ID.
+// gorm.ErrRecordNotFound is returned when the search belongs to another owner
+func (s *SavedSearchRepository) FindSavedSearchByID(id string, owner string) (*models.SavedSearch, error) {
+	var search models.SavedSearch
+
+	err := s.datastore.Debug().Where("id = ? AND owner = ?", id, owner).First(&search).Error
+	if err != nil {
+		return &search, err
+	}
+
+	return &search, nil
+}
+
+// DeleteSavedSearch will delete a saved search of the owner and the record of the notifications sent for it.
+// gorm.ErrRecordNotFound is returned when the search belongs to another owner
+func (s *SavedSearchRepository) DeleteSavedSearch(id string, owner string) error {
+	query := s.datastore.Debug().Where("id = ? AND owner = ?", id, owner).Delete(&models.SavedSearch{})
+	if query.Error != nil {
+		return query.Error
+	}
+
+	if query.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	// the records left behind when this fails are never read, there is no search with the id anymore
+	return s.datastore.Debug().Where("saved_search_id = ?", id).Delete(&models.SavedSearchNotification{}).Error
+}
+
+// FindSavedSearchesToNotify will find the saved searches matching the pet whose owners have not been told about it yet
+func (s *SavedSearchRepository) FindSavedSearchesToNotify(pet *models.Pet) ([]models.SavedSearch, error) {
+	var candidates []models.SavedSearch
+
+	if pet.Status != "available" {
+		return []models.SavedSearch{}, nil
+	}
+
+	// the category is filtered by the database, the tags are compared in Go with the rest of the criteria
+	err := s.datastore.Debug().
+		Where("category = '' OR LOWER(category) = LOWER(?)", pet.Category.Name).
+		Where("id NOT IN ?", s.datastore.
+			Table("saved_search_notifications").
+			Select("saved_search_id").
+			Where("pet_id = ?", pet.ID).
+			SubQuery()).
+		Order("id ASC").
+		Find(&candidates).Error
+	if err != nil {
+		return []models.SavedSearch{}, err
+	}
+
+	searches := []models.SavedSearch{}
+	for _, candidate := range candidates {
+		if candidate.Matches(pet) {
+			searches = append(searches, candidate)
+		}
+	}
+
+	return searches, nil
+}
+
+// ClaimNotification will record that the owner of the saved search is being told about the pet.
+// Only one caller claims a search and a pet, the others get false and must not notify the owner
+func (s *SavedSearchRepository) ClaimNotification(searchID uint64, petID uint64) (bool, error) {
+	query := s.datastore.Debug().Exec(
+		`INSERT INTO saved_search_notifications (saved_search_id, pet_id, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+		searchID, petID, time.Now())
+	if query.Error != nil {
+		return false, query.Error
+	}
+
+	return query.RowsAffected == 1, nil
+}
+
+// ReleaseNotification will forget the claim of a notification that could not be sent, it is tried again the next time the pet is saved
+func (s *SavedSearchRepository) ReleaseNotification(searchID uint64, petID uint64) error {
+	return s.datastore.Debug().
+		Where("saved_search_id = ? AND pet_id = ?", searchID, petID).
+		Delete(&models.SavedSearchNotification{}).Error
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"regexp"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/go-test/deep"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *Suite) Test_repository_FindSavedSearchesToNotify() {
+	savedSearches := NewSavedSearchRepository(s.DB)
+
+	pet := &models.Pet{
+		ID:       4,
+		Name:     "rex",
+		Status:   "available",
+		Category: models.Category{Name: "Dogs"},
+		Tags:     []models.Tag{{Name: "small"}},
+	}
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "saved_searches" WHERE (category = '' OR LOWER(category) = LOWER($1)) `+
+			`AND (id NOT IN (SELECT saved_search_id FROM "saved_search_notifications" WHERE (pet_id = $2))) ORDER BY id ASC`)).
+		WithArgs("Dogs", 4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "category", "tags"}).
+			AddRow(1, "one@example.com", "dogs", "{small}").
+			AddRow(2, "two@example.com", "", "{small,cute}").
+			AddRow(3, "three@example.com", "", "{}"))
+
+	searches, err := savedSearches.FindSavedSearchesToNotify(pet)
+	require.NoError(s.T(), err)
+
+	expected := []models.SavedSearch{
+		{ID: 1, Email: "one@example.com", Category: "dogs", Tags: pq.StringArray{"small"}},
+		{ID: 3, Email: "three@example.com", Tags: pq.StringArray{}},
+	}
+	require.Nil(s.T(), deep.Equal(searches, expected))
+}
+
+func (s *Suite) Test_repository_FindSavedSearchesToNotify_unavailablePet() {
+	savedSearches := NewSavedSearchRepository(s.DB)
+
+	searches, err := savedSearches.FindSavedSearchesToNotify(&models.Pet{ID: 4, Status: "sold"})
+	require.NoError(s.T(), err)
+	require.Empty(s.T(), searches)
+}
+
+func (s *Suite) Test_repository_ClaimNotification() {
+	savedSearches := NewSavedSearchRepository(s.DB)
+
+	claim := regexp.QuoteMeta(`INSERT INTO saved_search_notifications (saved_search_id, pet_id, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`)
+	s.mock.ExpectExec(claim).
+		WithArgs(1, 4, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	claimed, err := savedSearches.ClaimNotification(1, 4)
+	require.NoError(s.T(), err)
+	require.True(s.T(), claimed)
+
+	// the notification was claimed by another save of the pet
+	s.mock.ExpectExec(claim).
+		WithArgs(1, 4, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	claimed, err = savedSearches.ClaimNotification(1, 4)
+	require.NoError(s.T(), err)
+	require.False(s.T(), claimed)
+}
+
+func (s *Suite) Test_repository_ReleaseNotification() {
+	savedSearches := NewSavedSearchRepository(s.DB)
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "saved_search_notifications" WHERE (saved_search_id = $1 AND pet_id = $2)`)).
+		WithArgs(1, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectCommit()
+
+	require.NoError(s.T(), savedSearches.ReleaseNotification(1, 4))
+}
+
+func (s *Suite) Test_repository_DeleteSavedSearch_notFound() {
+	savedSearches := NewSavedSearchRepository(s.DB)
+
+	// the search does not exist or belongs to another owner, the notifications are kept
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "saved_searches" WHERE (id = $1 AND owner = $2)`)).
+		WithArgs("9", "owner").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectCommit()
+
+	err := savedSearches.DeleteSavedSearch("9", "owner")
+	require.True(s.T(), gorm.IsRecordNotFoundError(err))
+}
+
+func (s *Suite) Test_repository_FindSavedSearchByID_owner() {
+	savedSearches := NewSavedSearchRepository(s.DB)
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "saved_searches" WHERE (id = $1 AND owner = $2) ORDER BY "saved_searches"."id" ASC LIMIT 1`)).
+		WithArgs("9", "other").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err := savedSearches.FindSavedSearchByID("9", "other")
+	require.True(s.T(), gorm.IsRecordNotFoundError(err))
+}
@@ -0,0 +1,58 @@
+package responses
+
+import (
+	"bytes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BufferedWriter keeps the body of the response in memory until the handler is done, so that the middlewares can
+// look at it before it is sent. The status code is recorded by the gin writer, which only sends it with the first write.
+// Send is not named Flush, which flushes the underlying writer
+type BufferedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// NewBufferedWriter will buffer the responses written to the writer, until Send is called
+func NewBufferedWriter(writer gin.ResponseWriter) *BufferedWriter {
+	return &BufferedWriter{ResponseWriter: writer}
+}
+
+// Body returns the body written so far
+func (w *BufferedWriter) Body() []byte {
+	return w.body.Bytes()
+}
+
+// WriteHeaderNow does nothing, the header is sent by Send
+func (w *BufferedWriter) WriteHeaderNow() {}
+
+// Write will buffer the data
+func (w *BufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+// WriteString will buffer the string
+func (w *BufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// Size returns the size of the buffered body
+func (w *BufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+// Written is always false, nothing is sent before Send
+func (w *BufferedWriter) Written() bool {
+	return false
+}
+
+// Send will send the buffered response
+func (w *BufferedWriter) Send() {
+	if w.body.Len() == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+
+	w.ResponseWriter.Write(w.body.Bytes())
+}
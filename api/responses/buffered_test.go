@@ -0,0 +1,45 @@
+package responses
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferedWriter(t *testing.T) {
+	var buffered []byte
+	recorder := serve(func(c *gin.Context) {
+		writer := NewBufferedWriter(c.Writer)
+		c.Writer = writer
+
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+		// nothing is sent until the body is sent
+		require.False(t, writer.ResponseWriter.Written())
+		buffered = append(buffered, writer.Body()...)
+
+		c.Writer = writer.ResponseWriter
+		writer.Send()
+		c.Abort()
+	}, "")
+
+	require.Equal(t, `{"id":1}`, string(buffered))
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	require.Equal(t, `{"id":1}`, recorder.Body.String())
+
+	// the empty responses only send their status
+	recorder = serve(func(c *gin.Context) {
+		writer := NewBufferedWriter(c.Writer)
+		c.Writer = writer
+
+		c.Status(http.StatusNoContent)
+
+		c.Writer = writer.ResponseWriter
+		writer.Send()
+		c.Abort()
+	}, "")
+
+	require.Equal(t, http.StatusNoContent, recorder.Code)
+	require.Empty(t, recorder.Body.String())
+}
@@ -0,0 +1,90 @@
+package responses
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// problemContentType is the media type of the errors described by RFC 7807
+const problemContentType = "application/problem+json"
+
+// APIResponse is the body of the error responses, it is the ApiResponse of the petstore spec
+type APIResponse struct {
+	XMLName xml.Name `json:"-" xml:"ApiResponse"`
+	Code    int      `json:"code" xml:"code"`
+	Type    string   `json:"type" xml:"type"`
+	Message string   `json:"message" xml:"message"`
+	// Errors lists the invalid fields of the request when there are any
+	Errors models.ValidationErrors `json:"errors,omitempty" xml:"errors,omitempty"`
+}
+
+// Problem is the body of the error responses when the client asks for application/problem+json, see RFC 7807
+type Problem struct {
+	Type   string                  `json:"type"`
+	Title  string                  `json:"title"`
+	Status int                     `json:"status"`
+	Detail string                  `json:"detail"`
+	Errors models.ValidationErrors `json:"errors,omitempty"`
+}
+
+// Error will reply with the status code and the message.
+// The message is sent to the client as is, it should never contain the text of an internal error
+func Error(c *gin.Context, code int, message string) {
+	render(c, APIResponse{Code: code, Type: "error", Message: message})
+}
+
+// ValidationError will reply with a 400 and the list of the invalid fields
+func ValidationError(c *gin.Context, errs models.ValidationErrors) {
+	render(c, APIResponse{Code: http.StatusBadRequest, Type: "error", Message: "Invalid input", Errors: errs})
+}
+
+// UnprocessableEntity will reply with a 422 and the list of the invalid fields, the body was understood but cannot be saved
+func UnprocessableEntity(c *gin.Context, errs models.ValidationErrors) {
+	render(c, APIResponse{Code: http.StatusUnprocessableEntity, Type: "error", Message: "Invalid input", Errors: errs})
+}
+
+// InternalError will reply with a 500, the cause of the error should be logged by the caller
+func InternalError(c *gin.Context) {
+	Error(c, http.StatusInternalServerError, "Internal server error")
+}
+
+// NotFound is the handler of the routes that do not exist
+func NotFound(c *gin.Context) {
+	Error(c, http.StatusNotFound, "Not found")
+}
+
+// render will send the error in the format asked by the client (JSON, XML or problem+json) and stop the other handlers
+func render(c *gin.Context, response APIResponse) {
+	if !wantsProblem(c.GetHeader("Accept")) {
+		Render(c, response.Code, response)
+		c.Abort()
+		return
+	}
+
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(response.Code),
+		Status: response.Code,
+		Detail: response.Message,
+		Errors: response.Errors,
+	}
+
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(response.Code, problem)
+}
+
+// wantsProblem will tell if the Accept header asks for application/problem+json
+func wantsProblem(accept string) bool {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(mediaRange, ";", 2)[0])
+		if strings.EqualFold(mediaType, problemContentType) {
+			return true
+		}
+	}
+
+	return false
+}
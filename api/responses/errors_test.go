@@ -0,0 +1,81 @@
+package responses
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(handler gin.HandlerFunc, accept string) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.GET("/", handler, func(c *gin.Context) {
+		c.String(http.StatusOK, "the next handlers should not run")
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func TestError(t *testing.T) {
+	recorder := serve(func(c *gin.Context) {
+		Error(c, http.StatusNotFound, "Pet not found")
+	}, "application/json")
+
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+	require.Equal(t, "application/json; charset=utf-8", recorder.Header().Get("Content-Type"))
+	require.Equal(t, `{"code":404,"type":"error","message":"Pet not found"}`, recorder.Body.String())
+}
+
+func TestInternalError(t *testing.T) {
+	recorder := serve(InternalError, "")
+
+	require.Equal(t, http.StatusInternalServerError, recorder.Code)
+	require.Equal(t, `{"code":500,"type":"error","message":"Internal server error"}`, recorder.Body.String())
+}
+
+func TestValidationError_problem(t *testing.T) {
+	recorder := serve(func(c *gin.Context) {
+		ValidationError(c, models.ValidationErrors{{Field: "name", Code: models.CodeRequired, Message: "cannot be empty"}})
+	}, "application/json;q=0.5, application/problem+json")
+
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+	require.Equal(t,
+		`{"type":"about:blank","title":"Bad Request","status":400,"detail":"Invalid input",`+
+			`"errors":[{"field":"name","code":"required","message":"cannot be empty"}]}`,
+		recorder.Body.String())
+}
+
+func TestWantsProblem(t *testing.T) {
+	for accept, expected := range map[string]bool{
+		"":                                    false,
+		"*/*":                                 false,
+		"application/json":                    false,
+		"application/problem+json":            true,
+		"Application/Problem+JSON; q=0.9":     true,
+		"text/html, application/problem+json": true,
+	} {
+		require.Equal(t, expected, wantsProblem(accept), accept)
+	}
+}
+
+func TestError_xml(t *testing.T) {
+	recorder := serve(func(c *gin.Context) {
+		Error(c, http.StatusNotFound, "Pet not found")
+	}, "application/xml")
+
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+	require.Equal(t, "application/xml; charset=utf-8", recorder.Header().Get("Content-Type"))
+	require.Equal(t, `<ApiResponse><code>404</code><type>error</type><message>Pet not found</message></ApiResponse>`, recorder.Body.String())
+}
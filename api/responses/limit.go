@@ -0,0 +1,79 @@
+package responses
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errBodyTooLarge is returned by the reads of a body after its limit
+var errBodyTooLarge = errors.New("the body is too large")
+
+// LimitBody will make the reads of the body fail after the limit, so that a large body is never read whole.
+// The bodies whose Content-Length is above the limit are answered with a 413 right away and the handler should stop.
+// A body that is already limited to less is left as it is
+func LimitBody(c *gin.Context, limit int64) bool {
+	if limit <= 0 || c.Request.Body == nil {
+		return true
+	}
+
+	if c.Request.ContentLength > limit {
+		BodyTooLarge(c, limit)
+		return false
+	}
+
+	if body, limited := c.Request.Body.(*limitedBody); limited && body.limit <= limit {
+		return true
+	}
+
+	c.Request.Body = &limitedBody{ReadCloser: c.Request.Body, limit: limit, remaining: limit}
+	return true
+}
+
+// BodyLimitExceeded will tell if the reads of the body failed because of the limit set by LimitBody, and what it is
+func BodyLimitExceeded(r *http.Request) (int64, bool) {
+	if body, limited := r.Body.(*limitedBody); limited && body.exceeded {
+		return body.limit, true
+	}
+
+	return 0, false
+}
+
+// BodyTooLarge will send a 413 for a body that is larger than the limit
+func BodyTooLarge(c *gin.Context, limit int64) {
+	Error(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("The body cannot be larger than %d bytes", limit))
+}
+
+// limitedBody is a body whose reads fail after the limit, like with http.MaxBytesReader.
+// It remembers that the limit was exceeded, the parsers of the forms do not return the errors of the reads as they are
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errBodyTooLarge
+	}
+
+	// one more byte than the limit is read to know if the body is larger
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return n, err
+	}
+
+	n = int(b.remaining)
+	b.remaining = 0
+	b.exceeded = true
+	return n, errBodyTooLarge
+}
@@ -0,0 +1,48 @@
+package responses
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// offeredFormats are the formats the responses can be sent in, JSON is used when the client has no preference
+var offeredFormats = []string{binding.MIMEJSON, binding.MIMEXML, binding.MIMEXML2}
+
+// Render will send the object as XML when the Accept header asks for it, and as JSON otherwise
+func Render(c *gin.Context, code int, obj interface{}) {
+	if WantsXML(c) {
+		c.XML(code, obj)
+		return
+	}
+
+	c.JSON(code, obj)
+}
+
+// WantsXML will tell if the client prefers XML over JSON
+func WantsXML(c *gin.Context) bool {
+	switch c.NegotiateFormat(offeredFormats...) {
+	case binding.MIMEXML, binding.MIMEXML2:
+		return true
+	default:
+		return false
+	}
+}
+
+// SentXML will tell if the body of the request is XML, any other content type is read as JSON
+func SentXML(c *gin.Context) bool {
+	switch c.ContentType() {
+	case binding.MIMEXML, binding.MIMEXML2:
+		return true
+	default:
+		return false
+	}
+}
+
+// Bind will read the body of the request as XML or JSON depending on its content type
+func Bind(c *gin.Context, obj interface{}) error {
+	if SentXML(c) {
+		return c.ShouldBindWith(obj, binding.XML)
+	}
+
+	return c.ShouldBindWith(obj, binding.JSON)
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// dispatchByParam will send the request to the handler registered for the exact value of the url param,
+// and to the fallback handler for any other value.
+// The gin router does not allow a static path and a wildcard at the same position (e.g /pet/findByStatus and /pet/:id),
+// see: https://github.com/gin-gonic/gin/issues/1301
+func dispatchByParam(param string, handlers map[string]gin.HandlerFunc, fallback gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handler, found := handlers[c.Param(param)]
+		if !found {
+			handler = fallback
+		}
+
+		handler(c)
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatchByParam(t *testing.T) {
+	handler := func(name string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.String(http.StatusOK, name)
+		}
+	}
+
+	r := gin.New()
+	r.GET("/pet/:id", dispatchByParam("id", map[string]gin.HandlerFunc{
+		"findByStatus": handler("status"),
+		"findByTags":   handler("tags"),
+	}, handler("id")))
+
+	for path, expected := range map[string]string{
+		"/pet/findByStatus?status=sold": "status",
+		"/pet/findByTags?tags=small":    "tags",
+		"/pet/1":                        "id",
+		"/pet/xfindByStatusx":           "id",
+		"/pet/findByStatusx":            "id",
+	} {
+		req, err := http.NewRequest("GET", path, nil)
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+
+		require.Equal(t, expected, recorder.Body.String(), path)
+	}
+}
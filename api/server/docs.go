@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+)
+
+// swaggerUIPage is the page of the Swagger UI, the assets are served next to it from the binary
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Petstore API</title>
+  <link rel="stylesheet" href="swagger-ui.css">
+  <link rel="icon" type="image/png" href="favicon-32x32.png" sizes="32x32">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="swagger-ui-bundle.js"></script>
+  <script src="swagger-ui-standalone-preset.js"></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({
+        url: %q,
+        dom_id: "#swagger-ui",
+        presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+        layout: "StandaloneLayout"
+      });
+    };
+  </script>
+</body>
+</html>
+`
+
+// swaggerUI will serve the Swagger UI showing the document at the url.
+// The route must end with a *filepath wildcard
+func swaggerUI(documentURL string) gin.HandlerFunc {
+	page := []byte(fmt.Sprintf(swaggerUIPage, documentURL))
+	assets := http.FileServer(swaggerFiles.HTTP)
+
+	return func(c *gin.Context) {
+		path := c.Param("filepath")
+		if path == "/" || path == "/index.html" {
+			c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+			return
+		}
+
+		file, err := swaggerFiles.HTTP.Open(path)
+		if err != nil {
+			responses.NotFound(c)
+			return
+		}
+		file.Close()
+
+		c.Request.URL.Path = path
+		assets.ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/openapi"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	jsonContentType    = "application/json"
+	xmlContentType     = "application/xml"
+	problemContentType = "application/problem+json"
+)
+
+// apiDocument will serve the OpenAPI document that the requests are validated against
+func apiDocument(validator *openapi.Validator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, validator.Document())
+	}
+}
+
+// describeAPI will describe the routes of the table in an OpenAPI document,
+// the schemas are the ones the table added the models to
+func describeAPI(routes []route, schemas openapi.Schemas) openapi.Document {
+	describeModels(schemas)
+
+	described := make([]openapi.Route, 0, len(routes))
+	for _, route := range routes {
+		described = append(described, openapi.Route{Method: route.method, Path: route.path, Operations: route.operations})
+	}
+
+	info := openapi.Info{
+		Title: "Petstore",
+		Description: "The errors are sent as an ApiResponse, in JSON or in XML like the successful responses, " +
+			"or as an RFC 7807 problem when the client accepts application/problem+json. " +
+			"Unknown routes and methods are answered with a 404.",
+		Version: "1.0.0",
+	}
+
+	return openapi.Generate(info, described, openapi.Components{
+		Schemas: schemas,
+		SecuritySchemes: map[string]openapi.SecurityScheme{
+			"api_key": {Type: "apiKey", In: "header", Name: "api_key"},
+		},
+	})
+}
+
+// describeModels will add what the tags of the models cannot tell
+func describeModels(schemas openapi.Schemas) {
+	pet := schemas["Pet"]
+	pet.Required = []string{"name"}
+	// a pet without a status is saved and sent with an empty one, it is only left out of findByStatus
+	pet.Properties["status"].Enum = append([]string{""}, petStatuses()...)
+	pet.Properties["status"].Description = "empty when the pet has no status"
+	pet.Properties["photoUrls"].Items.Format = "uri"
+	pet.Properties["id"].Description = "ignored when a pet is added"
+
+	// the validation errors encode themselves as error elements
+	schemas["APIResponse"].Properties["errors"].XML = &openapi.XML{Name: "errors", Wrapped: true}
+	schemas["FieldError"].XML = &openapi.XML{Name: "error"}
+
+	schemas["SavedSearch"].Properties["email"].Format = "email"
+	schemas["BulkResult"].Properties["status"].Enum = []string{
+		models.BulkCreated, models.BulkUpdated, models.BulkDeleted, models.BulkFailed, models.BulkSkipped,
+	}
+	schemas["FieldError"].Properties["code"].Enum = []string{
+		models.CodeRequired, models.CodeTooLong, models.CodeInvalidValue, models.CodeInvalidURL, models.CodeInvalidBody,
+		models.CodeTooLarge,
+	}
+}
+
+// petStatuses returns the statuses of the pets in a stable order
+func petStatuses() []string {
+	statuses := make([]string, 0, len(models.PetStatuses))
+	for status := range models.PetStatuses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	return statuses
+}
+
+// rendered describes a response that is sent in JSON or in XML depending on the Accept header
+func rendered(description string, schema *openapi.Schema) openapi.Response {
+	return openapi.Response{Description: description, Content: map[string]openapi.MediaType{
+		jsonContentType: {Schema: schema},
+		xmlContentType:  {Schema: schema},
+	}}
+}
+
+// image describes a response that sends the content of an uploaded image
+func image(description string) openapi.Response {
+	binary := &openapi.Schema{Type: "string", Format: "binary"}
+	response := openapi.Response{Description: description, Content: map[string]openapi.MediaType{
+		"image/*":              {Schema: binary},
+		"multipart/byteranges": {Schema: binary},
+	}}
+
+	return withHeaders(cached(response), map[string]openapi.Header{
+		"Content-Disposition": {Description: "the name of the uploaded file", Schema: &openapi.Schema{Type: "string"}},
+		"Accept-Ranges":       {Schema: &openapi.Schema{Type: "string"}},
+	})
+}
+
+// jsonOnly describes a response that is always sent in JSON
+func jsonOnly(description string, schema *openapi.Schema) openapi.Response {
+	return openapi.Response{Description: description, Content: map[string]openapi.MediaType{
+		jsonContentType: {Schema: schema},
+	}}
+}
+
+// failure describes an error response, sent as an ApiResponse or as a problem
+func failure(description string) openapi.Response {
+	response := rendered(description, &openapi.Schema{Ref: "#/components/schemas/APIResponse"})
+	response.Content[problemContentType] = openapi.MediaType{Schema: &openapi.Schema{Ref: "#/components/schemas/Problem"}}
+
+	return response
+}
+
+// paginated adds the pagination headers to the response
+func paginated(response openapi.Response) openapi.Response {
+	return withHeaders(response, map[string]openapi.Header{
+		"Link":          {Description: "link to the next page, sent when there is one", Schema: &openapi.Schema{Type: "string"}},
+		"X-Total-Count": {Description: "number of matching pets, sent when count is true", Schema: &openapi.Schema{Type: "integer"}},
+	})
+}
+
+// cached adds the headers of the conditional requests to the response
+func cached(response openapi.Response) openapi.Response {
+	return withHeaders(cachedList(response), map[string]openapi.Header{
+		"Last-Modified": {Schema: &openapi.Schema{Type: "string"}},
+	})
+}
+
+// cachedList adds the headers of the conditional requests to a list, the lists are only revalidated with their ETag
+func cachedList(response openapi.Response) openapi.Response {
+	return withHeaders(response, map[string]openapi.Header{
+		"ETag":          {Schema: &openapi.Schema{Type: "string"}},
+		"Cache-Control": {Schema: &openapi.Schema{Type: "string"}},
+	})
+}
+
+func withHeaders(response openapi.Response, headers map[string]openapi.Header) openapi.Response {
+	if response.Headers == nil {
+		response.Headers = map[string]openapi.Header{}
+	}
+	for name, header := range headers {
+		response.Headers[name] = header
+	}
+
+	return response
+}
+
+// conditionalParams are the headers used to revalidate a cached response
+func conditionalParams() []openapi.Parameter {
+	return []openapi.Parameter{
+		{Name: "If-None-Match", In: "header", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "If-Modified-Since", In: "header", Schema: &openapi.Schema{Type: "string"}},
+	}
+}
+
+func int64Ptr(value int64) *int64 {
+	return &value
+}
+
+func intPtr(value int) *int {
+	return &value
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/notifications"
+	"github.com/YannHulot/petstore/api/openapi"
+	"github.com/YannHulot/petstore/api/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/require"
+)
+
+func newRouter(t *testing.T) *gin.Engine {
+	return newRouterWithDB(t, nil, models.Config{})
+}
+
+func newRouterWithDB(t *testing.T, db *gorm.DB, config models.Config) *gin.Engine {
+	notifier, err := notifications.NewNotifier(models.Config{Notifier: "log"})
+	require.NoError(t, err)
+
+	// nothing is uploaded by the tests of the routes
+	store, err := storage.NewLocal(filepath.Join(os.TempDir(), "petstore-uploads"))
+	require.NoError(t, err)
+
+	return CreateRouter(db, nil, notifier, store, config)
+}
+
+func serve(router *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	return recorder
+}
+
+func TestOpenAPIDocumentDescribesEveryRoute(t *testing.T) {
+	router := newRouter(t)
+
+	recorder := serve(router, "GET", "/api/v1/openapi.json")
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var document openapi.Document
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &document))
+	require.Equal(t, openapi.Version, document.OpenAPI)
+
+	for _, route := range router.Routes() {
+		if strings.HasPrefix(route.Path, "/api/v1/docs/") {
+			continue
+		}
+
+		path := regexp.MustCompile(`:(\w+)`).ReplaceAllString(route.Path, "{$1}")
+		operation := document.Paths[path][strings.ToLower(route.Method)]
+		require.NotNil(t, operation, "%s %s is not described", route.Method, route.Path)
+		require.NotEmpty(t, operation.Responses, "%s %s has no responses", route.Method, route.Path)
+	}
+
+	// findByStatus and findByTags share the route of the ids
+	require.Equal(t, "findPetsByStatus", document.Paths["/api/v1/pet/findByStatus"]["get"].OperationID)
+	require.Equal(t, "findPetsByTags", document.Paths["/api/v1/pet/findByTags"]["get"].OperationID)
+
+	// the form only changes the fields it supplies
+	form := document.Paths["/api/v1/pet/{id}"]["post"].RequestBody.Content["application/x-www-form-urlencoded"].Schema
+	require.Equal(t, "left unchanged when missing or empty", form.Properties["name"].Description)
+	require.Equal(t, "left unchanged when missing or empty", form.Properties["status"].Description)
+
+	pet := document.Components.Schemas["Pet"]
+	require.Equal(t, []string{"name"}, pet.Required)
+	require.Equal(t, []string{"", "available", "pending", "sold"}, pet.Properties["status"].Enum)
+	// the lengths and the XML names come from the tags of the models
+	require.Equal(t, 255, *pet.Properties["name"].MaxLength)
+	require.Equal(t, &openapi.XML{Name: "photoUrls", Wrapped: true}, pet.Properties["photoUrls"].XML)
+	require.Equal(t, &openapi.XML{Name: "photoUrl"}, pet.Properties["photoUrls"].Items.XML)
+	require.Equal(t, &openapi.XML{Name: "Pet"}, pet.XML)
+	require.Equal(t, &openapi.XML{Name: "ApiResponse"}, document.Components.Schemas["APIResponse"].XML)
+	require.Contains(t, document.Components.Schemas["APIResponse"].Properties, "errors")
+}
+
+func TestSwaggerUI(t *testing.T) {
+	router := newRouter(t)
+
+	recorder := serve(router, "GET", "/api/v1/docs/")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `url: "/api/v1/openapi.json"`)
+
+	recorder = serve(router, "GET", "/api/v1/docs/swagger-ui-bundle.js")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NotZero(t, recorder.Body.Len())
+
+	recorder = serve(router, "GET", "/api/v1/docs/missing.js")
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+	require.JSONEq(t, `{"code":404,"type":"error","message":"Not found"}`, recorder.Body.String())
+}
+
+// TestStrictValidation goes through the handlers with the responses checked against the document,
+// a handler that drifts from the document answers with a 500
+func TestStrictValidation(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	db, err := gorm.Open("postgres", sqlDB)
+	require.NoError(t, err)
+
+	router := newRouterWithDB(t, db, models.Config{StrictValidation: true})
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE ("pets"."id" = $1) ORDER BY "pets"."id" ASC LIMIT 1`)).
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status", "photos_urls"}).
+			AddRow(1, "doggie", "available", "{https://example.com/doggie.png}"))
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "tags" WHERE ("pet_id" IN ($1)) ORDER BY "tags"."id" ASC`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}).AddRow(1, "small", 2))
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "categories" WHERE ("pet_id" IN ($1)) ORDER BY "categories"."id" ASC`)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	recorder := serve(router, "GET", "/api/v1/pet/1")
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// a pet without a status is sent with an empty one
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "pets" WHERE ("pets"."id" = $1) ORDER BY "pets"."id" ASC LIMIT 1`)).
+		WithArgs("2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "status"}).AddRow(2, "rover", ""))
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "tags" WHERE ("pet_id" IN ($1)) ORDER BY "tags"."id" ASC`)).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+	mock.ExpectQuery(regexp.QuoteMeta(
+		`SELECT * FROM "categories" WHERE ("pet_id" IN ($1)) ORDER BY "categories"."id" ASC`)).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"pet_id", "name", "id"}))
+
+	recorder = serve(router, "GET", "/api/v1/pet/2")
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	require.Contains(t, recorder.Body.String(), `"status":""`)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// the invalid requests do not reach the handlers
+	for path, expected := range map[string]string{
+		"/api/v1/pet/findByStatus?status=lost": `{"field":"status","code":"invalid_value","message":"must be one of available, pending or sold"}`,
+		"/api/v1/pets?limit=0":                 `{"field":"limit","code":"invalid_value","message":"must be at least 1"}`,
+	} {
+		recorder := serve(router, "GET", path)
+		require.Equal(t, http.StatusBadRequest, recorder.Code, path)
+		require.JSONEq(t, `{"code":400,"type":"error","message":"Invalid input","errors":[`+expected+`]}`, recorder.Body.String(), path)
+	}
+
+	// the invalid ids are answered like the handlers do
+	for _, path := range []string{
+		"/api/v1/pet/abc", "/api/v1/pet/0", "/api/v1/pet/-1", "/api/v1/pet/99999999999999999999",
+		"/api/v1/pet/1/images/abc", "/api/v2/pets/abc",
+	} {
+		recorder := serve(router, "GET", path)
+		require.Equal(t, http.StatusBadRequest, recorder.Code, path)
+		require.JSONEq(t, `{"code":400,"type":"error","message":"Invalid ID supplied"}`, recorder.Body.String(), path)
+	}
+
+	recorder = serve(router, "DELETE", "/api/v1/pet/1")
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	// the v2 API answers the well formed pets that do not match the document with a 422
+	req, _ := http.NewRequest("POST", "/api/v2/pets", strings.NewReader(`{"status":"lost"}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusUnprocessableEntity, recorder.Code, recorder.Body.String())
+}
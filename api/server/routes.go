@@ -0,0 +1,609 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/YannHulot/petstore/api/caching"
+	"github.com/YannHulot/petstore/api/controllers"
+	"github.com/YannHulot/petstore/api/idempotency"
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/openapi"
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/gin-gonic/gin"
+)
+
+// route is a route of the API with the operations that describe it,
+// the router and the OpenAPI document are both made from the table of the routes so that they cannot drift apart
+type route struct {
+	method     string
+	path       string
+	handler    gin.HandlerFunc
+	operations []openapi.Operation
+}
+
+// handlers holds what serves the routes of the table
+type handlers struct {
+	pets          controllers.PetController
+	petsV2        controllers.PetV2Controller
+	savedSearches controllers.SavedSearchController
+	idempotency   *idempotency.Store
+	validator     *openapi.Validator
+	config        models.Config
+}
+
+// apiRoutes is the table of the routes of the API.
+// The schemas of the models used by the operations are added to the schemas.
+// The routes without operations, like the documentation, are left out of the OpenAPI document.
+// The bodies of the uploads cannot be much larger than the maximum size of an image
+func apiRoutes(h handlers, schemas openapi.Schemas) []route {
+	pet := schemas.Of(models.Pet{})
+	pets := schemas.Of(models.Pets{})
+	// the lists of pets encode themselves in a pets element
+	pets.XML = &openapi.XML{Name: "pets", Wrapped: true}
+	searchResult := schemas.Of(models.PetSearchResult{})
+	savedSearch := schemas.Of(models.SavedSearch{})
+	apiResponse := schemas.Of(responses.APIResponse{})
+	bulkResponse := schemas.Of(models.BulkResponse{})
+	schemas.Of(responses.Problem{})
+
+	// the invalid ids are answered like the handlers do
+	idParam := openapi.Parameter{
+		Name:           "id",
+		In:             "path",
+		Required:       true,
+		Schema:         &openapi.Schema{Type: "integer", Format: "int64", Minimum: int64Ptr(1)},
+		InvalidMessage: controllers.InvalidIDMessage,
+	}
+	statuses := &openapi.Schema{Type: "string", Enum: petStatuses()}
+	// the form can change the name without the status, a missing or empty status is left unchanged
+	formStatus := &openapi.Schema{
+		Type:        "string",
+		Description: "left unchanged when missing or empty",
+		Enum:        append([]string{""}, petStatuses()...),
+	}
+	explode := true
+	apiKeyRequired := []map[string][]string{{"api_key": {}}}
+
+	bulkMode := openapi.Parameter{
+		Name:        "mode",
+		In:          "query",
+		Description: "atomic saves every item or none of them, best-effort saves the valid items and reports the others",
+		Schema:      &openapi.Schema{Type: "string", Enum: []string{"atomic", "best-effort"}, Default: "atomic"},
+	}
+	// the pets are validated by the handler so that the invalid ones are reported in the results in best-effort mode
+	bulkPets := &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+		jsonContentType: {Schema: &openapi.Schema{
+			Type:  "array",
+			Items: &openapi.Schema{Type: "object", Description: "a Pet, the tags and the category are always created"},
+		}},
+	}}
+	bulkResponses := func() map[string]openapi.Response {
+		invalid := failure("Invalid input, or an item of an atomic request failed and nothing was saved")
+		invalid.Content[jsonContentType] = openapi.MediaType{Schema: &openapi.Schema{
+			Type:        "object",
+			Description: "an APIResponse, or a BulkResponse when an item failed",
+		}}
+
+		return map[string]openapi.Response{
+			"200": jsonOnly("The result of every item is listed in results", bulkResponse),
+			"400": invalid,
+			"500": failure("Internal server error"),
+		}
+	}
+
+	deleteBulkResponses := bulkResponses()
+	deleteBulkResponses["401"] = failure("Missing or invalid api_key")
+
+	// the retries of a request with the same key get the response of the first one
+	idempotencyKey := openapi.Parameter{
+		Name:        idempotency.HeaderName,
+		In:          "header",
+		Description: "unique key of the request, its response is replayed to the retries with the same api_key, which is required",
+		Schema:      &openapi.Schema{Type: "string", MaxLength: intPtr(255)},
+	}
+
+	searchParams := []openapi.Parameter{
+		{Name: "q", In: "query", Description: "full text search on the name, the category and the tags", Schema: &openapi.Schema{Type: "string", MaxLength: intPtr(200)}},
+		{Name: "name", In: "query", Description: "part of the name, the case is ignored", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "category", In: "query", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "tags", In: "query", Explode: &explode, Schema: &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}}},
+		{Name: "status", In: "query", Explode: &explode, Schema: &openapi.Schema{Type: "array", Items: statuses}},
+		{Name: "createdAfter", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+		{Name: "createdBefore", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+		{Name: "updatedAfter", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+		{Name: "updatedBefore", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+		{Name: "sort", In: "query", Description: "comma separated fields, a leading - sorts in descending order", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "limit", In: "query", Schema: &openapi.Schema{Type: "integer", Minimum: int64Ptr(1), Maximum: int64Ptr(1000), Default: 100}},
+		{Name: "offset", In: "query", Schema: &openapi.Schema{Type: "integer", Minimum: int64Ptr(0), Default: 0}},
+		{Name: "facets", In: "query", Description: "count the matches per status, category and tag", Schema: &openapi.Schema{Type: "boolean"}},
+	}
+
+	patchBody := &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+		"application/merge-patch+json": {Schema: &openapi.Schema{Type: "object"}},
+		"application/json-patch+json": {Schema: &openapi.Schema{Type: "array", Items: &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"op":    {Type: "string", Enum: []string{"add", "remove", "replace", "move", "copy", "test"}},
+				"path":  {Type: "string"},
+				"from":  {Type: "string"},
+				"value": {},
+			},
+			Required: []string{"op", "path"},
+		}}},
+	}}
+
+	petBody := &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+		jsonContentType: {Schema: pet},
+		xmlContentType:  {Schema: pet},
+	}}
+
+	return []route{
+		{
+			method:  "POST",
+			path:    "/api/v1/pet",
+			handler: idempotency.Idempotent(h.idempotency, h.pets.SavePet),
+			operations: []openapi.Operation{{
+				Summary:     "Add a new pet to the store",
+				OperationID: "addPet",
+				Tags:        []string{"pet"},
+				Parameters:  []openapi.Parameter{idempotencyKey},
+				RequestBody: petBody,
+				Responses: map[string]openapi.Response{
+					"200": rendered("The saved pet", pet),
+					"400": failure("Invalid input, the invalid fields are listed in errors"),
+					"409": failure("A request with the same Idempotency-Key is being processed"),
+					"422": failure("The Idempotency-Key was used with another request"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		// the bulk routes share their path with the id of the pets, like findByStatus
+		{
+			method: "POST",
+			path:   "/api/v1/pet/:id",
+			handler: dispatchByParam("id", map[string]gin.HandlerFunc{
+				"bulk": idempotency.Idempotent(h.idempotency, h.pets.SavePets),
+			}, h.pets.UpdatePetWithFormData),
+			operations: []openapi.Operation{{
+				Path:        "/api/v1/pet/bulk",
+				Summary:     "Add pets to the store, they are inserted in batches",
+				OperationID: "addPets",
+				Tags:        []string{"pet"},
+				Parameters:  []openapi.Parameter{bulkMode, idempotencyKey},
+				RequestBody: bulkPets,
+				Responses:   bulkResponses(),
+			}, {
+				Summary:     "Update a pet in the store with form data",
+				OperationID: "updatePetWithForm",
+				Tags:        []string{"pet"},
+				Parameters:  []openapi.Parameter{idParam},
+				RequestBody: &openapi.RequestBody{Content: map[string]openapi.MediaType{
+					"application/x-www-form-urlencoded": {Schema: &openapi.Schema{
+						Type: "object",
+						Properties: map[string]*openapi.Schema{
+							"name":   {Type: "string", Description: "left unchanged when missing or empty"},
+							"status": formStatus,
+						},
+					}},
+				}},
+				Responses: map[string]openapi.Response{
+					"200": rendered("The updated pet", pet),
+					"400": failure("Invalid ID supplied or invalid input"),
+					"404": failure("Pet not found"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "POST",
+			path:    "/api/v1/pet/:id/uploadImage",
+			handler: h.pets.UploadFile,
+			operations: []openapi.Operation{{
+				Summary:     "Upload an image, its URL is added to the photo URLs of the pet",
+				OperationID: "uploadFile",
+				Tags:        []string{"pet"},
+				MaxBodySize: h.pets.MaxUploadBodySize(),
+				Parameters:  []openapi.Parameter{idParam},
+				RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+					"multipart/form-data": {Schema: &openapi.Schema{
+						Type: "object",
+						Properties: map[string]*openapi.Schema{
+							"additionalMetadata": {Type: "string"},
+							"file":               {Type: "string", Format: "binary"},
+						},
+						Required: []string{"additionalMetadata", "file"},
+					}},
+				}},
+				Responses: map[string]openapi.Response{
+					"200": rendered("The image was uploaded", apiResponse),
+					"400": failure("Invalid ID supplied, invalid form value or invalid image"),
+					"404": failure("Pet not found"),
+					"413": failure("The file is too large"),
+					"415": failure("The file is not a PNG, JPEG, WebP or GIF image"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "GET",
+			path:    "/api/v1/pet/:id/images/:imageId",
+			handler: h.pets.GetImage,
+			operations: []openapi.Operation{{
+				Summary:     "Download an image of a pet, a part of it with a Range header",
+				OperationID: "getPetImage",
+				Tags:        []string{"pet"},
+				Parameters: append([]openapi.Parameter{
+					idParam,
+					{
+						Name:           "imageId",
+						In:             "path",
+						Required:       true,
+						Schema:         &openapi.Schema{Type: "integer", Format: "int64", Minimum: int64Ptr(1)},
+						InvalidMessage: controllers.InvalidIDMessage,
+					},
+					{Name: "Range", In: "header", Description: "e.g bytes=0-1023", Schema: &openapi.Schema{Type: "string"}},
+					{Name: "If-Range", In: "header", Schema: &openapi.Schema{Type: "string"}},
+				}, conditionalParams()...),
+				Responses: map[string]openapi.Response{
+					"200": image("The image"),
+					"206": image("The requested range of the image, multipart/byteranges when several ranges were requested"),
+					"304": {Description: "The image has not changed"},
+					"400": failure("Invalid ID supplied"),
+					"404": failure("The pet has no image with this id"),
+					"416": {Description: "The range cannot be satisfied", Content: map[string]openapi.MediaType{
+						"text/plain": {Schema: &openapi.Schema{Type: "string"}},
+					}},
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "PUT",
+			path:    "/api/v1/pet",
+			handler: h.pets.UpdatePet,
+			operations: []openapi.Operation{{
+				Summary:     "Update an existing pet",
+				OperationID: "updatePet",
+				Tags:        []string{"pet"},
+				RequestBody: petBody,
+				Responses: map[string]openapi.Response{
+					"200": rendered("The updated pet", pet),
+					"400": failure("Invalid input, the invalid fields are listed in errors"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "PUT",
+			path:    "/api/v1/pet/bulk",
+			handler: h.pets.UpdatePets,
+			operations: []openapi.Operation{{
+				Summary:     "Update existing pets, they are found by the ids of the payloads",
+				OperationID: "updatePets",
+				Tags:        []string{"pet"},
+				Parameters:  []openapi.Parameter{bulkMode},
+				RequestBody: bulkPets,
+				Responses:   bulkResponses(),
+			}},
+		},
+		{
+			method:  "PATCH",
+			path:    "/api/v1/pet/:id",
+			handler: h.pets.PatchPet,
+			operations: []openapi.Operation{{
+				Summary:     "Partially update a pet with a JSON Merge Patch or a JSON Patch",
+				OperationID: "patchPet",
+				Tags:        []string{"pet"},
+				Parameters:  []openapi.Parameter{idParam},
+				RequestBody: patchBody,
+				Responses: map[string]openapi.Response{
+					"200": rendered("The patched pet", pet),
+					"400": failure("Invalid ID supplied, invalid patch or invalid patched pet"),
+					"404": failure("Pet not found"),
+					"409": failure("The patch cannot be applied to the pet"),
+					"415": failure("The content type is not a patch format"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		// findByStatus and findByTags share their path with the id of the pets,
+		// they are matched exactly and every other value is treated as an id
+		// the pets read by id and by status can be revalidated with their ETag, the single pets with their Last-Modified date too
+		{
+			method: "GET",
+			path:   "/api/v1/pet/:id",
+			handler: dispatchByParam("id", map[string]gin.HandlerFunc{
+				"findByStatus": caching.Conditional(h.config.FindByStatusCacheMaxAge, h.pets.FindPetByStatus),
+				"findByTags":   h.pets.FindPetByTags,
+			}, caching.Conditional(h.config.PetCacheMaxAge, h.pets.FindPetByID)),
+			operations: []openapi.Operation{
+				{
+					Summary:     "Find a pet by ID",
+					OperationID: "getPetById",
+					Tags:        []string{"pet"},
+					Parameters:  append([]openapi.Parameter{idParam}, conditionalParams()...),
+					Responses: map[string]openapi.Response{
+						"200": cached(rendered("The pet", pet)),
+						"304": {Description: "The pet has not changed"},
+						"400": failure("Invalid ID supplied"),
+						"404": failure("Pet not found"),
+						"500": failure("Internal server error"),
+					},
+				},
+				{
+					Path:        "/api/v1/pet/findByStatus",
+					Summary:     "Find pets by status, sorted by id",
+					OperationID: "findPetsByStatus",
+					Tags:        []string{"pet"},
+					Parameters: append([]openapi.Parameter{
+						{Name: "status", In: "query", Required: true, Explode: &explode, Schema: &openapi.Schema{Type: "array", Items: statuses}},
+						{Name: "limit", In: "query", Schema: &openapi.Schema{Type: "integer", Minimum: int64Ptr(1), Maximum: int64Ptr(1000), Default: 100}},
+						{Name: "cursor", In: "query", Description: "id of the last pet of the previous page", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+						{Name: "count", In: "query", Description: "send the number of matching pets in X-Total-Count", Schema: &openapi.Schema{Type: "boolean"}},
+					}, conditionalParams()...),
+					Responses: map[string]openapi.Response{
+						"200": cachedList(paginated(rendered("The pets", pets))),
+						"304": {Description: "The pets have not changed"},
+						"400": failure("Invalid status value or invalid pagination"),
+						"500": failure("Internal server error"),
+					},
+				},
+				{
+					Path:        "/api/v1/pet/findByTags",
+					Summary:     "Find pets by tags",
+					OperationID: "findPetsByTags",
+					Tags:        []string{"pet"},
+					Parameters: []openapi.Parameter{
+						{Name: "tags", In: "query", Required: true, Explode: &explode, Schema: &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}}},
+						{Name: "match", In: "query", Description: "whether the pets need any or all of the tags", Schema: &openapi.Schema{Type: "string", Enum: []string{"any", "all"}, Default: "any"}},
+						{Name: "limit", In: "query", Schema: &openapi.Schema{Type: "integer", Minimum: int64Ptr(1), Maximum: int64Ptr(1000), Default: 100}},
+						{Name: "cursor", In: "query", Description: "id of the last pet of the previous page", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+						{Name: "count", In: "query", Description: "send the number of matching pets in X-Total-Count", Schema: &openapi.Schema{Type: "boolean"}},
+					},
+					Responses: map[string]openapi.Response{
+						"200": paginated(rendered("The pets", pets)),
+						"400": failure("Invalid tag value, invalid match value or invalid pagination"),
+						"500": failure("Internal server error"),
+					},
+				},
+			},
+		},
+		{
+			method: "DELETE",
+			path:   "/api/v1/pet/:id",
+			handler: dispatchByParam("id", map[string]gin.HandlerFunc{
+				"bulk": h.pets.DeletePets,
+			}, h.pets.DeletePet),
+			operations: []openapi.Operation{{
+				Path:        "/api/v1/pet/bulk",
+				Summary:     "Delete pets, their tags and their categories",
+				OperationID: "deletePets",
+				Tags:        []string{"pet"},
+				Parameters:  []openapi.Parameter{bulkMode},
+				Security:    apiKeyRequired,
+				RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+					jsonContentType: {Schema: &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "integer", Format: "int64"}}},
+				}},
+				Responses: deleteBulkResponses,
+			}, {
+				Summary:     "Delete a pet",
+				OperationID: "deletePet",
+				Tags:        []string{"pet"},
+				Parameters:  []openapi.Parameter{idParam},
+				Security:    apiKeyRequired,
+				Responses: map[string]openapi.Response{
+					"200": rendered("The pet was deleted", &openapi.Schema{Type: "object"}),
+					"400": failure("Invalid ID supplied"),
+					"401": failure("Missing or invalid api_key"),
+					"404": failure("Pet not found"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "GET",
+			path:    "/api/v1/pets",
+			handler: h.pets.SearchPets,
+			operations: []openapi.Operation{{
+				Summary:     "Search the pets",
+				OperationID: "searchPets",
+				Tags:        []string{"pet"},
+				Parameters:  searchParams,
+				Responses: map[string]openapi.Response{
+					"200": jsonOnly("A page of matching pets", searchResult),
+					"400": failure("Invalid or unknown query parameter"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "POST",
+			path:    "/api/v1/savedSearches",
+			handler: h.savedSearches.SaveSearch,
+			operations: []openapi.Operation{{
+				Summary:     "Save a search to be told about the matching pets",
+				OperationID: "saveSearch",
+				Tags:        []string{"savedSearch"},
+				Security:    apiKeyRequired,
+				RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+					jsonContentType: {Schema: savedSearch},
+				}},
+				Responses: map[string]openapi.Response{
+					"200": jsonOnly("The saved search", savedSearch),
+					"400": failure("Invalid input"),
+					"401": failure("Missing or invalid api_key"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "GET",
+			path:    "/api/v1/savedSearches/:id",
+			handler: h.savedSearches.FindSavedSearchByID,
+			operations: []openapi.Operation{{
+				Summary:     "Find a saved search by ID",
+				OperationID: "getSavedSearchById",
+				Tags:        []string{"savedSearch"},
+				Parameters:  []openapi.Parameter{idParam},
+				Security:    apiKeyRequired,
+				Responses: map[string]openapi.Response{
+					"200": jsonOnly("The saved search", savedSearch),
+					"400": failure("Invalid ID supplied"),
+					"401": failure("Missing or invalid api_key"),
+					"404": failure("Saved search not found, or saved with another api_key"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "DELETE",
+			path:    "/api/v1/savedSearches/:id",
+			handler: h.savedSearches.DeleteSavedSearch,
+			operations: []openapi.Operation{{
+				Summary:     "Delete a saved search",
+				OperationID: "deleteSavedSearch",
+				Tags:        []string{"savedSearch"},
+				Parameters:  []openapi.Parameter{idParam},
+				Security:    apiKeyRequired,
+				Responses: map[string]openapi.Response{
+					"200": jsonOnly("The saved search was deleted", &openapi.Schema{Type: "object"}),
+					"400": failure("Invalid ID supplied"),
+					"401": failure("Missing or invalid api_key"),
+					"404": failure("Saved search not found, or saved with another api_key"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "GET",
+			path:    "/api/v2/pets",
+			handler: h.petsV2.ListPets,
+			operations: []openapi.Operation{{
+				Summary:     "List the pets matching the filters",
+				OperationID: "listPets",
+				Tags:        []string{"pets"},
+				Parameters:  searchParams,
+				Responses: map[string]openapi.Response{
+					"200": jsonOnly("A page of matching pets", searchResult),
+					"400": failure("Invalid or unknown query parameter"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "POST",
+			path:    "/api/v2/pets",
+			handler: idempotency.Idempotent(h.idempotency, h.petsV2.CreatePet),
+			operations: []openapi.Operation{{
+				Summary:           "Create a pet",
+				OperationID:       "createPet",
+				InvalidBodyStatus: http.StatusUnprocessableEntity,
+				Tags:              []string{"pets"},
+				Parameters:        []openapi.Parameter{idempotencyKey},
+				RequestBody:       petBody,
+				Responses: map[string]openapi.Response{
+					"201": withHeaders(rendered("The created pet", pet), map[string]openapi.Header{
+						"Location": {Description: "url of the created pet", Schema: &openapi.Schema{Type: "string"}},
+					}),
+					"400": failure("The body is not a valid document"),
+					"409": failure("A request with the same Idempotency-Key is being processed"),
+					"422": failure("The pet cannot be saved, the invalid fields are listed in errors, or the Idempotency-Key was used with another request"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "GET",
+			path:    "/api/v2/pets/:id",
+			handler: caching.Conditional(h.config.PetCacheMaxAge, h.petsV2.GetPet),
+			operations: []openapi.Operation{{
+				Summary:     "Get a pet",
+				OperationID: "getPet",
+				Tags:        []string{"pets"},
+				Parameters:  append([]openapi.Parameter{idParam}, conditionalParams()...),
+				Responses: map[string]openapi.Response{
+					"200": cached(rendered("The pet", pet)),
+					"304": {Description: "The pet has not changed"},
+					"400": failure("Invalid ID supplied"),
+					"404": failure("Pet not found"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "PUT",
+			path:    "/api/v2/pets/:id",
+			handler: h.petsV2.ReplacePet,
+			operations: []openapi.Operation{{
+				Summary:           "Replace a pet, including its tags and category",
+				OperationID:       "replacePet",
+				InvalidBodyStatus: http.StatusUnprocessableEntity,
+				Tags:              []string{"pets"},
+				Parameters:        []openapi.Parameter{idParam},
+				RequestBody:       petBody,
+				Responses: map[string]openapi.Response{
+					"200": rendered("The replaced pet", pet),
+					"400": failure("Invalid ID supplied or the body is not a valid document"),
+					"404": failure("Pet not found"),
+					"422": failure("The pet cannot be saved, the invalid fields are listed in errors"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "PATCH",
+			path:    "/api/v2/pets/:id",
+			handler: h.petsV2.PatchPet,
+			operations: []openapi.Operation{{
+				Summary:     "Partially update a pet with a JSON Merge Patch or a JSON Patch",
+				OperationID: "updatePet",
+				Tags:        []string{"pets"},
+				Parameters:  []openapi.Parameter{idParam},
+				RequestBody: patchBody,
+				Responses: map[string]openapi.Response{
+					"200": rendered("The patched pet", pet),
+					"400": failure("Invalid ID supplied or invalid patch"),
+					"404": failure("Pet not found"),
+					"409": failure("The patch cannot be applied to the pet"),
+					"415": failure("The content type is not a patch format"),
+					"422": failure("The patched pet cannot be saved, the invalid fields are listed in errors"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		{
+			method:  "DELETE",
+			path:    "/api/v2/pets/:id",
+			handler: h.petsV2.DeletePet,
+			operations: []openapi.Operation{{
+				Summary:     "Delete a pet",
+				OperationID: "removePet",
+				Tags:        []string{"pets"},
+				Parameters:  []openapi.Parameter{idParam},
+				Security:    apiKeyRequired,
+				Responses: map[string]openapi.Response{
+					"204": {Description: "The pet was deleted"},
+					"400": failure("Invalid ID supplied"),
+					"401": failure("Missing or invalid api_key"),
+					"404": failure("Pet not found"),
+					"500": failure("Internal server error"),
+				},
+			}},
+		},
+		// the document is generated from this table, the Swagger UI is served from the binary to work offline
+		{
+			method:  "GET",
+			path:    "/api/v1/openapi.json",
+			handler: apiDocument(h.validator),
+			operations: []openapi.Operation{{
+				Summary:     "This document",
+				OperationID: "getOpenAPIDocument",
+				Tags:        []string{"documentation"},
+				Responses: map[string]openapi.Response{
+					"200": jsonOnly("The OpenAPI document of the API", &openapi.Schema{Type: "object"}),
+				},
+			}},
+		},
+		{method: "GET", path: "/api/v1/docs/*filepath", handler: swaggerUI("/api/v1/openapi.json")},
+	}
+}
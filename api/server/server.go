@@ -1,39 +1,78 @@
 package server
 
 import (
+	"time"
+
 	"github.com/YannHulot/petstore/api/controllers"
+	"github.com/YannHulot/petstore/api/idempotency"
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/notifications"
+	"github.com/YannHulot/petstore/api/openapi"
 	"github.com/YannHulot/petstore/api/repository"
+	"github.com/YannHulot/petstore/api/responses"
+	"github.com/YannHulot/petstore/api/services"
+	"github.com/YannHulot/petstore/api/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 )
 
-// CreateRouter will create the routes and the router
-func CreateRouter(db *gorm.DB) *gin.Engine {
+// CreateRouter will create the routes and the router.
+// The read queries are sent to the replicas when there are any, replicas can be nil.
+// The owners of the saved searches are told about the matching pets by the notifier, the uploaded images are kept by the store
+func CreateRouter(db *gorm.DB, replicas *models.ReplicaPool, notifier notifications.Notifier, store storage.Storage, config models.Config) *gin.Engine {
 	// force colors to show in terminal
 	gin.ForceConsoleColor()
 
 	// this router comes with a logger and a recovery middleware by default
 	router := gin.Default()
 
-	// version the api for future proofing and easy refactoring
-	apiV1 := router.Group("/api/v1")
+	// the unknown routes are answered with the same error format as the handlers
+	router.NoRoute(responses.NotFound)
+
+	// the requests are checked against the OpenAPI document of the routes before they reach the controllers,
+	// the responses are checked too in strict mode.
+	// The document is described on the first request, once the table of the routes is built
+	schemas := openapi.Schemas{}
+	var routes []route
+	validator := openapi.NewValidator(func() openapi.Document {
+		return describeAPI(routes, schemas)
+	}, config.StrictValidation)
+	router.Use(validator.Validate)
 
 	// create a repository that gives access to the DB
-	petRepository := repository.NewPetRepository(db)
-
-	// create a controller that contains all the handlers that we need
-	petController := controllers.NewPetController(petRepository)
-	{
-		apiV1.POST("/pet", petController.SavePet)
-		apiV1.POST("/pet/:id", petController.UpdatePetWithFormData)
-		apiV1.POST("/pet/:id/uploadImage", petController.UploadFile)
-		apiV1.PUT("/pet", petController.UpdatePet)
-		// WARNING: the route below handles multiple cases:
-		// - pet/1
-		// - pet/findByStatus?status=available
-		// - pet/findByStatus?status=available&status=sold
-		apiV1.GET("/pet/:id", petController.FindPetByIDOrStatus)
-		apiV1.DELETE("/pet/:id", petController.DeletePet)
+	petRepository := repository.NewPetRepositoryWithReplicas(db, replicas)
+
+	// the saved searches are checked every time a pet is saved
+	savedSearchRepository := repository.NewSavedSearchRepository(db)
+	dispatcher := notifications.NewDispatcher(savedSearchRepository, notifier)
+
+	// both versions of the api share the business logic of the service
+	petService := services.NewPetService(petRepository, dispatcher, store)
+
+	// the clients retry the creations with the same Idempotency-Key, they get the response of the first one
+	// the expired responses are swept every minute, the store lives as long as the router
+	idempotencyStore := idempotency.NewStore(config.IdempotencyKeyTTL)
+	idempotencyStore.StartSweeps(time.Minute)
+
+	// the images are kept by the store and served by the api, their URLs start with the public URL
+	imageService := services.NewImageService(petRepository, store, config.PublicURL, services.ImageLimits{
+		MaxSize:      int64(config.MaxUploadSize),
+		MaxDimension: config.MaxImageDimension,
+		MaxPixels:    config.MaxImagePixels,
+	})
+
+	// the routes are versioned for future proofing and easy refactoring,
+	// v2 follows the REST conventions rather than the petstore spec
+	routes = apiRoutes(handlers{
+		pets:          controllers.NewPetController(petService, imageService),
+		petsV2:        controllers.NewPetV2Controller(petService),
+		savedSearches: controllers.NewSavedSearchController(savedSearchRepository),
+		idempotency:   idempotencyStore,
+		validator:     validator,
+		config:        config,
+	}, schemas)
+	for _, route := range routes {
+		router.Handle(route.method, route.path, route.handler)
 	}
 
 	return router
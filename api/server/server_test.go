@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIdempotentPetCreation makes sure that the retries of a creation with the same Idempotency-Key save a single pet
+func TestIdempotentPetCreation(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	db, err := gorm.Open("postgres", sqlDB)
+	require.NoError(t, err)
+
+	router := newRouterWithDB(t, db, models.Config{StrictValidation: true, IdempotencyKeyTTL: time.Hour})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "pets"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/pet", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "5f0c6b1e")
+		req.Header.Set("api_key", "test-key")
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	first := create(`{"name":"rex","status":"available"}`)
+	require.Equal(t, http.StatusOK, first.Code, first.Body.String())
+
+	retry := create(`{"name":"rex","status":"available"}`)
+	require.Equal(t, http.StatusOK, retry.Code)
+	require.Equal(t, first.Body.String(), retry.Body.String())
+	require.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+
+	require.Equal(t, http.StatusUnprocessableEntity, create(`{"name":"max","status":"available"}`).Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestBulkRoutes makes sure that the bulk routes are not mistaken for the routes of a pet id
+func TestBulkRoutes(t *testing.T) {
+	router := newRouterWithDB(t, nil, models.Config{StrictValidation: true, IdempotencyKeyTTL: time.Hour})
+
+	for _, method := range []string{"POST", "PUT", "DELETE"} {
+		req, _ := http.NewRequest(method, "/api/v1/pet/bulk?mode=all", strings.NewReader(`[]`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("api_key", "test-key")
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		require.Equal(t, http.StatusBadRequest, recorder.Code, method)
+		require.JSONEq(t, `{"code":400,"type":"error","message":"Invalid input","errors":[`+
+			`{"field":"mode","code":"invalid_value","message":"must be one of atomic or best-effort"}]}`, recorder.Body.String(), method)
+	}
+
+	req, _ := http.NewRequest("DELETE", "/api/v1/pet/bulk", strings.NewReader(`[1]`))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+// TestPetImageResponses makes sure that the responses of the images match the OpenAPI document
+func TestPetImageResponses(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	db, err := gorm.Open("postgres", sqlDB)
+	require.NoError(t, err)
+
+	router := newRouterWithDB(t, db, models.Config{StrictValidation: true, IdempotencyKeyTTL: time.Hour})
+
+	key := "pets/1/5d41402abc4b2a76b9719d911017c592"
+	path := filepath.Join(os.TempDir(), "petstore-uploads", filepath.FromSlash(key))
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte("\x89PNG\r\n\x1a\n"), 0644))
+	defer os.Remove(path)
+
+	for _, test := range []struct {
+		rangeHeader string
+		code        int
+	}{{"", http.StatusOK}, {"bytes=0-3", http.StatusPartialContent}, {"bytes=0-1,4-5", http.StatusPartialContent}, {"bytes=50-", http.StatusRequestedRangeNotSatisfiable}} {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT pet_images.* FROM "pet_images" JOIN pets`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "pet_id", "key", "filename", "content_type", "size"}).
+				AddRow(3, 1, key, "rex.png", "image/png", 8))
+
+		req, _ := http.NewRequest("GET", "/api/v1/pet/1/images/3", nil)
+		if test.rangeHeader != "" {
+			req.Header.Set("Range", test.rangeHeader)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		require.Equal(t, test.code, recorder.Code, test.rangeHeader)
+	}
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUploadBodySize makes sure that the bodies of the uploads are not read past the limit, whether their size is known or not
+func TestUploadBodySize(t *testing.T) {
+	router := newRouterWithDB(t, nil, models.Config{StrictValidation: true, MaxUploadSize: 1000})
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "large.png")
+	require.NoError(t, err)
+	_, err = part.Write(append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100000)...))
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteField("additionalMetadata", "large"))
+	require.NoError(t, writer.Close())
+
+	for _, knownSize := range []bool{true, false} {
+		var content io.Reader = bytes.NewReader(body.Bytes())
+		if !knownSize {
+			content = ioutil.NopCloser(content)
+		}
+
+		req, _ := http.NewRequest("POST", "/api/v1/pet/1/uploadImage", content)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		require.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code, knownSize)
+		require.JSONEq(t, `{"code":413,"type":"error","message":"The body cannot be larger than 66536 bytes"}`, recorder.Body.String())
+	}
+}
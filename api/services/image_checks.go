@@ -0,0 +1,123 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	// the decoders of the allowed types, except WebP whose header is read by webpConfig
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+
+	"github.com/YannHulot/petstore/api/models"
+)
+
+// allowedImageTypes are the types of the images that can be uploaded, they are detected from the first bytes of the files
+var allowedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/webp": true,
+	"image/gif":  true,
+}
+
+// sniffLength is the number of bytes used by http.DetectContentType
+const sniffLength = 512
+
+// checkImage will make sure that the upload is an image of an allowed type within the limits, its type is returned.
+// The content type sent by the client is not trusted, the type is detected from the first bytes of the file.
+// The size of the image is read from its header, the pixels are never decoded
+func (s *ImageService) checkImage(upload Upload) (string, error) {
+	if upload.Size > s.limits.MaxSize {
+		return "", ErrImageTooLarge
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(upload.Content, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if !allowedImageTypes[contentType] {
+		return "", ErrUnsupportedImage
+	}
+
+	_, err = upload.Content.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	var config image.Config
+	if contentType == "image/webp" {
+		config, err = webpConfig(head)
+	} else {
+		config, _, err = image.DecodeConfig(upload.Content)
+	}
+
+	if err != nil {
+		return "", models.ValidationErrors{{Field: "file", Code: models.CodeInvalidValue, Message: "is not a valid image"}}
+	}
+
+	if config.Width > s.limits.MaxDimension || config.Height > s.limits.MaxDimension ||
+		int64(config.Width)*int64(config.Height) > int64(s.limits.MaxPixels) {
+		return "", models.ValidationErrors{{
+			Field: "file",
+			Code:  models.CodeInvalidValue,
+			Message: fmt.Sprintf("cannot be larger than %d pixels in width or height, or %d pixels in total, it is %dx%d",
+				s.limits.MaxDimension, s.limits.MaxPixels, config.Width, config.Height),
+		}}
+	}
+
+	_, err = upload.Content.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	return contentType, nil
+}
+
+// webpConfig will read the size of a WebP image from the header of its first chunk, there is no WebP decoder in the standard library.
+// The header is RIFF, the size of the file, WEBP, then a VP8 (lossy), VP8L (lossless) or VP8X (extended) chunk
+func webpConfig(head []byte) (image.Config, error) {
+	if len(head) < 30 || !bytes.Equal(head[0:4], []byte("RIFF")) || !bytes.Equal(head[8:12], []byte("WEBP")) {
+		return image.Config{}, fmt.Errorf("the WebP header is truncated")
+	}
+
+	// the data of the chunk starts after its FourCC and its size
+	data := head[20:]
+
+	var width, height int
+	switch string(head[12:16]) {
+	case "VP8 ":
+		// a 3 bytes frame tag, the 9d 01 2a start code, then the width and the height on 14 bits each
+		if !bytes.Equal(data[3:6], []byte{0x9d, 0x01, 0x2a}) {
+			return image.Config{}, fmt.Errorf("the VP8 start code is missing")
+		}
+		width = int(binary.LittleEndian.Uint16(data[6:8]) & 0x3fff)
+		height = int(binary.LittleEndian.Uint16(data[8:10]) & 0x3fff)
+	case "VP8L":
+		// the 0x2f signature, then the width - 1 and the height - 1 on 14 bits each
+		if data[0] != 0x2f {
+			return image.Config{}, fmt.Errorf("the VP8L signature is missing")
+		}
+		bits := binary.LittleEndian.Uint32(data[1:5])
+		width = int(bits&0x3fff) + 1
+		height = int(bits>>14&0x3fff) + 1
+	case "VP8X":
+		// 4 bytes of flags, then the width - 1 and the height - 1 of the canvas on 24 bits each
+		width = int(uint32(data[4])|uint32(data[5])<<8|uint32(data[6])<<16) + 1
+		height = int(uint32(data[7])|uint32(data[8])<<8|uint32(data[9])<<16) + 1
+	default:
+		return image.Config{}, fmt.Errorf("the WebP chunk %q is not supported", head[12:16])
+	}
+
+	if width == 0 || height == 0 {
+		return image.Config{}, fmt.Errorf("the WebP image is empty")
+	}
+
+	return image.Config{Width: width, Height: height}, nil
+}
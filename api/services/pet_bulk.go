@@ -0,0 +1,290 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/repository"
+	"github.com/jinzhu/gorm"
+)
+
+// MaxBulkSize is the maximum number of items in a bulk request
+const MaxBulkSize = 1000
+
+// errItemFailed rolls back the transaction of an atomic request when one of its items failed
+var errItemFailed = errors.New("an item of the bulk request failed")
+
+// CreatePets will sanitise, validate and save new pets, the pets are inserted in batches.
+// In atomic mode nothing is saved when a pet is invalid, otherwise the valid pets are saved and the invalid ones reported.
+// The error is only returned when the request itself is invalid or when the database failed in atomic mode
+func (s *PetService) CreatePets(pets []models.Pet, atomic bool) ([]models.BulkResult, error) {
+	err := validateBulkSize(len(pets))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BulkResult, len(pets))
+	var valid []int
+	for i := range pets {
+		results[i].Index = i
+
+		pets[i].ID = 0
+		pets[i].Sanitise()
+		if err := pets[i].Validate(); err != nil {
+			itemFailed(&results[i], err)
+			continue
+		}
+		valid = append(valid, i)
+	}
+
+	if atomic && hasFailures(results) {
+		skip(results)
+		return results, nil
+	}
+
+	toSave := make([]models.Pet, len(valid))
+	for j, i := range valid {
+		toSave[j] = pets[i]
+	}
+
+	err = s.repository.SavePets(toSave)
+	if err != nil && atomic {
+		return nil, err
+	}
+
+	if err != nil {
+		// the batch failed, the pets are saved one by one to only report the ones that cannot be saved
+		log.Printf("failed to save a batch of pets, saving them one by one: %v", err)
+		for j := range toSave {
+			toSave[j].ID = 0
+			err = s.repository.SavePets(toSave[j : j+1])
+			if err != nil {
+				itemFailed(&results[valid[j]], err)
+			}
+		}
+	}
+
+	for j, i := range valid {
+		if results[i].Status == models.BulkFailed {
+			continue
+		}
+
+		results[i].ID = toSave[j].ID
+		results[i].Status = models.BulkCreated
+		s.petSaved(&toSave[j])
+	}
+
+	return results, nil
+}
+
+// ReplacePets will replace the pets that have the ids of the payloads, including their tags and categories.
+// In atomic mode the pets are replaced in a single transaction, nothing is saved when one of them is invalid or does not exist
+func (s *PetService) ReplacePets(pets []models.Pet, atomic bool) ([]models.BulkResult, error) {
+	err := validateBulkSize(len(pets))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BulkResult, len(pets))
+	for i := range pets {
+		results[i] = models.BulkResult{Index: i, ID: pets[i].ID}
+
+		pets[i].Sanitise()
+		err := pets[i].Validate()
+		if pets[i].ID == 0 {
+			errs, _ := err.(models.ValidationErrors)
+			err = append(models.ValidationErrors{{Field: "id", Code: models.CodeRequired, Message: "cannot be empty"}}, errs...)
+		}
+
+		if err != nil {
+			itemFailed(&results[i], err)
+		}
+	}
+
+	replaced := make([]*models.Pet, len(pets))
+	err = s.bulk(results, atomic, func(repository repository.PetRepository, i int) error {
+		pet, err := repository.PatchPet(strconv.FormatUint(pets[i].ID, 10), replaceWith(&pets[i]))
+		if err != nil {
+			return err
+		}
+
+		results[i].Status = models.BulkUpdated
+		replaced[i] = pet
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if atomic && hasFailures(results) {
+		// the transaction was rolled back
+		return results, nil
+	}
+
+	for i, pet := range replaced {
+		// the pets whose transaction failed to commit are not saved
+		if results[i].Status == models.BulkUpdated {
+			s.petSaved(pet)
+		}
+	}
+
+	return results, nil
+}
+
+// DeletePets will delete the pets with the ids, their tags, their categories and their images.
+// In atomic mode the pets are deleted in a single transaction, nothing is deleted when one of them does not exist
+func (s *PetService) DeletePets(ids []uint64, atomic bool) ([]models.BulkResult, error) {
+	err := validateBulkSize(len(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BulkResult, len(ids))
+	for i, id := range ids {
+		results[i] = models.BulkResult{Index: i, ID: id}
+		if id == 0 {
+			itemFailed(&results[i], models.ValidationErrors{{Field: "id", Code: models.CodeInvalidValue, Message: "must be a positive integer"}})
+		}
+	}
+
+	images := make([][]models.PetImage, len(ids))
+	err = s.bulk(results, atomic, func(repository repository.PetRepository, i int) error {
+		var err error
+		images[i], err = repository.DeletePet(strconv.FormatUint(ids[i], 10))
+		if err != nil {
+			return err
+		}
+
+		results[i].Status = models.BulkDeleted
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the images are only removed once the deletion of their pet is committed
+	for i := range results {
+		if results[i].Status == models.BulkDeleted {
+			s.imagesDeleted(images[i])
+		}
+	}
+
+	return results, nil
+}
+
+// bulk will apply the change to every item that has not failed yet.
+// In atomic mode the items share a transaction, it is rolled back and the other items are skipped when one of them fails.
+// Otherwise every item has its own transaction and the items that fail are reported.
+// The error is only returned when the database failed in atomic mode
+func (s *PetService) bulk(results []models.BulkResult, atomic bool, apply func(repository repository.PetRepository, i int) error) error {
+	if !atomic {
+		for i := range results {
+			if results[i].Status == models.BulkFailed {
+				continue
+			}
+
+			// the status set by apply is replaced when the transaction fails to commit
+			err := s.repository.Transaction(func(repository repository.PetRepository) error {
+				return apply(repository, i)
+			})
+			itemFailed(&results[i], err)
+		}
+
+		return nil
+	}
+
+	if hasFailures(results) {
+		skip(results)
+		return nil
+	}
+
+	err := s.repository.Transaction(func(repository repository.PetRepository) error {
+		for i := range results {
+			err := apply(repository, i)
+			if err == nil {
+				continue
+			}
+
+			if _, invalid := err.(models.ValidationErrors); !invalid && !gorm.IsRecordNotFoundError(err) {
+				return err
+			}
+
+			itemFailed(&results[i], err)
+			return errItemFailed
+		}
+
+		return nil
+	})
+
+	if err == errItemFailed {
+		skip(results)
+		return nil
+	}
+
+	return err
+}
+
+// itemFailed will record the error of an item, nothing is recorded when there is no error
+func itemFailed(result *models.BulkResult, err error) {
+	if err == nil {
+		return
+	}
+
+	result.Status = models.BulkFailed
+
+	switch e := err.(type) {
+	case models.ValidationErrors:
+		result.Message = "Invalid input"
+		result.Errors = e
+	default:
+		if gorm.IsRecordNotFoundError(err) {
+			result.Message = "Pet not found"
+			return
+		}
+
+		// the details of the internal errors are only logged
+		log.Printf("failed to save the item %d of a bulk request: %v", result.Index, err)
+		result.Message = "Internal server error"
+	}
+}
+
+// hasFailures will tell if an item failed
+func hasFailures(results []models.BulkResult) bool {
+	for _, result := range results {
+		if result.Status == models.BulkFailed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// skip will mark the items that did not fail as skipped, they were not saved because another item failed
+func skip(results []models.BulkResult) {
+	for i := range results {
+		if results[i].Status != models.BulkFailed {
+			results[i].Status = models.BulkSkipped
+			results[i].Message = "Not saved because another item failed"
+		}
+	}
+}
+
+// validateBulkSize will make sure that a bulk request has at least one item and not too many
+func validateBulkSize(size int) error {
+	if size == 0 {
+		return models.ValidationErrors{{Field: "body", Code: models.CodeRequired, Message: "cannot be empty"}}
+	}
+
+	if size > MaxBulkSize {
+		return models.ValidationErrors{{
+			Field:   "body",
+			Code:    models.CodeInvalidValue,
+			Message: fmt.Sprintf("cannot have more than %d items", MaxBulkSize),
+		}}
+	}
+
+	return nil
+}
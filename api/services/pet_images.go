@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/repository"
+	"github.com/YannHulot/petstore/api/storage"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrImageNotFound is returned when the pet has no image with the requested id
+	ErrImageNotFound = errors.New("image not found")
+	// ErrImageTooLarge is returned when the uploaded file is larger than the limit
+	ErrImageTooLarge = errors.New("the image is too large")
+	// ErrUnsupportedImage is returned when the uploaded file is not an image of one of the allowed types
+	ErrUnsupportedImage = errors.New("the file is not a PNG, JPEG, WebP or GIF image")
+)
+
+// maxFilenameLength is the size of the column storing the names of the uploaded files
+const maxFilenameLength = 255
+
+// ImageService keeps the images of the pets in the storage and records them in the database
+type ImageService struct {
+	repository repository.PetRepository
+	storage    storage.Storage
+	// publicURL is the URL the clients use to reach the API, the URLs of the images start with it
+	publicURL string
+	limits    ImageLimits
+}
+
+// ImageLimits are the limits of the uploaded images
+type ImageLimits struct {
+	// MaxSize is the maximum size of the file in bytes
+	MaxSize int64
+	// MaxDimension is the maximum width and height in pixels
+	MaxDimension int
+	// MaxPixels is the maximum number of pixels once decoded
+	MaxPixels int
+}
+
+// NewImageService will create a new ImageService
+func NewImageService(repository repository.PetRepository, storage storage.Storage, publicURL string, limits ImageLimits) *ImageService {
+	return &ImageService{
+		repository: repository,
+		storage:    storage,
+		publicURL:  strings.TrimSuffix(publicURL, "/"),
+		limits:     limits,
+	}
+}
+
+// MaxSize is the maximum size of an uploaded image in bytes
+func (s *ImageService) MaxSize() int64 {
+	return s.limits.MaxSize
+}
+
+// Upload is a file sent by a client
+type Upload struct {
+	// Filename is the name of the file on the computer of the client, it is never used to store the file
+	Filename string
+	Size     int64
+	// Content is read once to be checked and once more to be stored
+	Content io.ReadSeeker
+}
+
+// UploadImage will check the image, keep it under a generated key, record it and add its URL to the photo URLs of the pet.
+// ErrImageTooLarge and ErrUnsupportedImage are returned when the file is rejected, a models.ValidationErrors when the image is invalid
+// or too large once decoded. ErrPetNotFound is returned when there is no pet with the id, the image is not kept then
+func (s *ImageService) UploadImage(petID string, upload Upload) (*models.PetImage, error) {
+	id, err := strconv.ParseUint(petID, 10, 64)
+	if err != nil {
+		return nil, ErrPetNotFound
+	}
+
+	contentType, err := s.checkImage(upload)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := storage.NewKey("pets/" + petID)
+	if err != nil {
+		return nil, err
+	}
+
+	image := &models.PetImage{
+		PetID:       id,
+		Key:         key,
+		Filename:    imageFilename(upload.Filename),
+		ContentType: contentType,
+		Size:        upload.Size,
+	}
+
+	err = s.storage.Put(key, image.ContentType, upload.Content, upload.Size)
+	if err != nil {
+		return nil, fmt.Errorf("could not store the image: %v", err)
+	}
+
+	err = s.repository.SavePetImage(image, s.imageURL)
+	if err != nil {
+		// the storage only keeps the images that are recorded
+		deleteErr := s.storage.Delete(key)
+		if deleteErr != nil {
+			log.Printf("failed to delete the image %s that could not be recorded: %v", key, deleteErr)
+		}
+
+		return nil, notFound(err)
+	}
+
+	return image, nil
+}
+
+// OpenImage will find the image of the pet and open its content, the content must be closed.
+// ErrImageNotFound is returned when the pet has no image with the id, the images of the other pets are not found either
+func (s *ImageService) OpenImage(petID string, imageID string) (*models.PetImage, storage.Object, error) {
+	image, err := s.repository.FindPetImage(petID, imageID)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := s.storage.Open(image.Key)
+	if err == storage.ErrNotFound {
+		log.Printf("the image %d is recorded but %s is missing from the storage", image.ID, image.Key)
+		return nil, nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return image, content, nil
+}
+
+// imageURL will return the URL where the clients can download the image
+func (s *ImageService) imageURL(image *models.PetImage) string {
+	return fmt.Sprintf("%s/api/v1/pet/%d/images/%d", s.publicURL, image.PetID, image.ID)
+}
+
+// imageFilename will keep the last element of the name sent by the client, without its directories and its control characters
+func imageFilename(filename string) string {
+	filename = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filename)
+
+	filename = path.Base(strings.Replace(filename, `\`, "/", -1))
+	if filename == "." || filename == "/" || filename == ".." {
+		return ""
+	}
+
+	if len(filename) > maxFilenameLength {
+		// the end of the name is kept for its extension, without cutting a character in two
+		start := len(filename) - maxFilenameLength
+		for start < len(filename) && !utf8.RuneStart(filename[start]) {
+			start++
+		}
+		filename = filename[start:]
+	}
+
+	return strings.TrimSpace(filename)
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"encoding/json"
+
+	"github.com/YannHulot/petstore/api/models"
+	jsonpatch "github.com/evanphx/json-patch"
+)
+
+// the media types of the patches, see RFC 7396 and RFC 6902
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// applyPetPatch will change the pet with the patch, the pet is only changed when the result is valid
+func applyPetPatch(pet *models.Pet, contentType string, patchDocument []byte) error {
+	original, err := json.Marshal(pet)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	if contentType == MergePatchContentType {
+		if !json.Valid(patchDocument) {
+			return ErrInvalidPatch
+		}
+
+		patched, err = jsonpatch.MergePatch(original, patchDocument)
+		if err != nil {
+			return ErrPatchConflict
+		}
+	} else {
+		operations, err := jsonpatch.DecodePatch(patchDocument)
+		if err != nil {
+			return ErrInvalidPatch
+		}
+
+		patched, err = operations.Apply(original)
+		if err != nil {
+			return ErrPatchConflict
+		}
+	}
+
+	var result models.Pet
+	err = json.Unmarshal(patched, &result)
+	if err != nil {
+		return models.ValidationErrors{{
+			Field:   "body",
+			Code:    models.CodeInvalidBody,
+			Message: "the patched pet is not a valid pet",
+		}}
+	}
+
+	if result.ID != pet.ID {
+		return models.ValidationErrors{{Field: "id", Code: models.CodeInvalidValue, Message: "cannot be changed"}}
+	}
+
+	result.Sanitise()
+
+	err = result.Validate()
+	if err != nil {
+		return err
+	}
+
+	// the timestamps are managed by the database layer
+	result.CreatedAt = pet.CreatedAt
+	result.UpdatedAt = pet.UpdatedAt
+	*pet = result
+
+	return nil
+}
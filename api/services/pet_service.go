@@ -0,0 +1,281 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/repository"
+	"github.com/YannHulot/petstore/api/storage"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrPetNotFound is returned when there is no pet with the requested id
+	ErrPetNotFound = errors.New("pet not found")
+	// ErrInvalidPatch is returned when the body of the request is not a valid patch document
+	ErrInvalidPatch = errors.New("the patch is not a valid document")
+	// ErrPatchConflict is returned when the patch cannot be applied to the stored pet, e.g a test operation failed
+	ErrPatchConflict = errors.New("the patch cannot be applied to the pet")
+)
+
+// PetListener is told about every pet that has been created or updated
+type PetListener interface {
+	PetSaved(pet models.Pet)
+}
+
+// PetService holds the business logic of the pets.
+// The versions of the API share it and only differ in how they map it to HTTP.
+// The invalid pets are reported with a models.ValidationErrors
+type PetService struct {
+	repository repository.PetRepository
+	// listener is told about the saved pets, it can be nil
+	listener PetListener
+	// storage keeps the uploaded images, they are removed from it with their pet
+	storage storage.Storage
+}
+
+// NewPetService will create a new PetService, the listener can be nil
+func NewPetService(repository repository.PetRepository, listener PetListener, storage storage.Storage) *PetService {
+	return &PetService{
+		repository: repository,
+		listener:   listener,
+		storage:    storage,
+	}
+}
+
+// Page is the page of pets requested by a client, the pets are sorted by id.
+// The cursor is the id of the last pet of the previous page
+type Page struct {
+	Limit  int
+	Cursor uint64
+	// Count asks for the number of pets on every page
+	Count bool
+}
+
+// PetPage is a page of pets
+type PetPage struct {
+	Pets []models.Pet
+	// HasNext tells if there are pets after the last one of the page
+	HasNext bool
+	// Total is only set when the count is requested
+	Total *int
+}
+
+// petSaved will tell the listener, if there is one, that the pet has been created or updated
+func (s *PetService) petSaved(pet *models.Pet) {
+	if s.listener != nil {
+		s.listener.PetSaved(*pet)
+	}
+}
+
+// CreatePet will sanitise, validate and save a new pet
+func (s *PetService) CreatePet(pet *models.Pet) (*models.Pet, error) {
+	pet.Sanitise()
+
+	err := pet.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := s.repository.SavePet(pet)
+	if err != nil {
+		return nil, err
+	}
+
+	s.petSaved(saved)
+	return saved, nil
+}
+
+// GetPet will find a pet by its id
+func (s *PetService) GetPet(id string) (*models.Pet, error) {
+	pet, err := s.repository.FindPetByID(id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return pet, nil
+}
+
+// UpdatePet will save the pet over the one that has its id, the id is read from the payload
+func (s *PetService) UpdatePet(pet *models.Pet) (*models.Pet, error) {
+	pet.Sanitise()
+
+	err := pet.Validate()
+	if pet.ID == 0 {
+		// the id is needed to know which pet to update
+		errs, _ := err.(models.ValidationErrors)
+		err = append(models.ValidationErrors{{Field: "id", Code: models.CodeRequired, Message: "cannot be empty"}}, errs...)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.repository.UpdatePet(pet)
+	if err != nil {
+		return nil, err
+	}
+
+	s.petSaved(updated)
+	return updated, nil
+}
+
+// ReplacePet will replace the pet that has the id, including its tags and category.
+// The payload does not need an id, when it has one it must be the same
+func (s *PetService) ReplacePet(id string, pet *models.Pet) (*models.Pet, error) {
+	pet.Sanitise()
+
+	err := pet.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	replaced, err := s.repository.PatchPet(id, replaceWith(pet))
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	s.petSaved(replaced)
+	return replaced, nil
+}
+
+// replaceWith returns the patch function that replaces the stored pet with the pet, the id and the dates are kept
+func replaceWith(pet *models.Pet) func(stored *models.Pet) error {
+	return func(stored *models.Pet) error {
+		if pet.ID != 0 && pet.ID != stored.ID {
+			return models.ValidationErrors{{Field: "id", Code: models.CodeInvalidValue, Message: "cannot be changed"}}
+		}
+
+		replacement := *pet
+		replacement.ID = stored.ID
+		replacement.CreatedAt = stored.CreatedAt
+		replacement.UpdatedAt = stored.UpdatedAt
+		*stored = replacement
+
+		return nil
+	}
+}
+
+// UpdatePetAttributes will change the name and the status of a pet, the empty values are left unchanged.
+// At least one of them is needed
+func (s *PetService) UpdatePetAttributes(id string, name string, status string) (*models.Pet, error) {
+	if len(name) == 0 && len(status) == 0 {
+		return nil, models.ValidationErrors{{
+			Field:   "name",
+			Code:    models.CodeRequired,
+			Message: "name or status must be supplied",
+		}}
+	}
+
+	err := models.ValidatePetAttributes(name, status)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.repository.UpdatePetAttributes(id, name, status)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	s.petSaved(updated)
+	return updated, nil
+}
+
+// PatchPet will apply a JSON Merge Patch or a JSON Patch, depending on the content type, to the pet with the id.
+// The patch is applied to the stored pet, including its tags and category, and the result is validated before being saved
+func (s *PetService) PatchPet(id string, contentType string, patchDocument []byte) (*models.Pet, error) {
+	patched, err := s.repository.PatchPet(id, func(pet *models.Pet) error {
+		return applyPetPatch(pet, contentType, patchDocument)
+	})
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	s.petSaved(patched)
+	return patched, nil
+}
+
+// DeletePet will delete the pet with the id, its tags, its category and its images
+func (s *PetService) DeletePet(id string) error {
+	images, err := s.repository.DeletePet(id)
+	if err != nil {
+		return notFound(err)
+	}
+
+	s.imagesDeleted(images)
+	return nil
+}
+
+// imagesDeleted will remove from the storage the images whose records have been deleted.
+// The pet is already deleted, the images that cannot be removed are only logged
+func (s *PetService) imagesDeleted(images []models.PetImage) {
+	for _, image := range images {
+		err := s.storage.Delete(image.Key)
+		if err != nil {
+			log.Printf("failed to delete the image %s of the deleted pet %d: %v", image.Key, image.PetID, err)
+		}
+	}
+}
+
+// FindPetsByStatus will find a page of the pets that have any of the statuses
+func (s *PetService) FindPetsByStatus(statuses []string, page Page) (*PetPage, error) {
+	// fetch one more pet than needed to know if there is a next page
+	pets, err := s.repository.FindPetByStatus(statuses, page.Cursor, page.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.petPage(*pets, page, func() (int, error) {
+		return s.repository.CountPetsByStatus(statuses)
+	})
+}
+
+// FindPetsByTags will find a page of the pets that have any of the tags, or all of them
+func (s *PetService) FindPetsByTags(tags []string, matchAll bool, page Page) (*PetPage, error) {
+	pets, err := s.repository.FindPetByTags(tags, matchAll, page.Cursor, page.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.petPage(*pets, page, func() (int, error) {
+		return s.repository.CountPetsByTags(tags, matchAll)
+	})
+}
+
+// SearchPets will find the pets matching the search
+func (s *PetService) SearchPets(search models.PetSearch) (*models.PetSearchResult, error) {
+	return s.repository.SearchPets(search)
+}
+
+// petPage will cut the pets to the size of the page, there is a next page when there are more pets than that
+func (s *PetService) petPage(pets []models.Pet, page Page, count func() (int, error)) (*PetPage, error) {
+	result := &PetPage{Pets: pets}
+	if len(pets) > page.Limit {
+		result.Pets = pets[:page.Limit]
+		result.HasNext = true
+	}
+
+	if result.Pets == nil {
+		result.Pets = []models.Pet{}
+	}
+
+	if page.Count {
+		total, err := count()
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	return result, nil
+}
+
+// notFound will replace the record not found errors of the database layer by ErrPetNotFound
+func notFound(err error) error {
+	if gorm.IsRecordNotFoundError(err) {
+		return ErrPetNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// unsignedPayload is sent instead of the hash of the body so that the uploads are streamed, not buffered to be hashed
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// signatureAlgorithm is the AWS Signature Version 4 used to sign the requests
+	signatureAlgorithm = "AWS4-HMAC-SHA256"
+)
+
+// S3 keeps the objects in a bucket of an S3 compatible service, e.g AWS S3 or MinIO.
+// The bucket is addressed in the path of the URLs (path style), it works with every S3 compatible service
+type S3 struct {
+	endpoint        *url.URL
+	region          string
+	bucket          string
+	accessKeyID     string
+	secretAccessKey string
+	client          *http.Client
+
+	// now is replaced in the tests to sign the requests at a known date
+	now func() time.Time
+}
+
+// NewS3 creates a new S3 storage, the endpoint is the URL of the service, e.g https://s3.eu-west-1.amazonaws.com
+func NewS3(endpoint string, region string, bucket string, accessKeyID string, secretAccessKey string) (*S3, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("the endpoint of the s3 storage must be an absolute http or https URL: %q", endpoint)
+	}
+
+	if region == "" || bucket == "" || accessKeyID == "" || secretAccessKey == "" {
+		return nil, fmt.Errorf("the region, the bucket and the credentials of the s3 storage are required")
+	}
+
+	return &S3{
+		endpoint:        parsed,
+		region:          region,
+		bucket:          bucket,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		client:          &http.Client{Timeout: time.Minute},
+		now:             time.Now,
+	}, nil
+}
+
+// objectURL will return the URL of the object of the key
+func (s *S3) objectURL(key string) string {
+	objectURL := *s.endpoint
+	objectURL.Path = strings.TrimSuffix(objectURL.Path, "/") + "/" + s.bucket + "/" + key
+
+	return objectURL.String()
+}
+
+// Put will upload the content to the object of the key
+func (s *S3) Put(key string, contentType string, content io.Reader, size int64) error {
+	err := validateKey(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PUT", s.objectURL(key), ioutil.NopCloser(content))
+	if err != nil {
+		return err
+	}
+
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+
+	return s.do(req, http.StatusOK)
+}
+
+// Open will ask for the size of the object of the key, its content is only downloaded when it is read
+func (s *S3) Open(key string) (Object, error) {
+	err := validateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("HEAD", s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.send(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	if res.ContentLength < 0 {
+		return nil, fmt.Errorf("the size of the object %s is unknown", key)
+	}
+
+	return &s3Object{
+		storage: s,
+		key:     key,
+		size:    res.ContentLength,
+	}, nil
+}
+
+// Delete will remove the object of the key
+func (s *S3) Delete(key string) error {
+	err := validateKey(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("DELETE", s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+
+	// deleting a missing object is not an error, S3 answers with a 204 and some services with a 404
+	return s.do(req, http.StatusNoContent, http.StatusOK, http.StatusNotFound)
+}
+
+// do will sign and send the request, the responses with another status than the expected ones are errors
+func (s *S3) do(req *http.Request, expected ...int) error {
+	res, err := s.send(req, expected...)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// send will sign and send the request, the response is returned when its status is one of the expected ones
+// and its body must be closed. ErrNotFound is returned for an unexpected 404
+func (s *S3) send(req *http.Request, expected ...int) (*http.Response, error) {
+	s.sign(req, unsignedPayload)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range expected {
+		if res.StatusCode == status {
+			return res, nil
+		}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	// the body of the errors is a small XML document that tells what went wrong
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+	return nil, fmt.Errorf("%s %s failed with the status %d: %s", req.Method, req.URL.Path, res.StatusCode, body)
+}
+
+// sign will add the AWS Signature Version 4 of the request in its Authorization header, every header is signed
+func (s *S3) sign(req *http.Request, payloadHash string) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		strings.Replace(req.URL.Query().Encode(), "+", "%20", -1),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{signatureAlgorithm, amzDate, scope, hashHex(canonicalRequest)}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signatureAlgorithm, s.accessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(signingKey, stringToSign))))
+}
+
+// s3Object reads an object from the offset with a range request, seeking only moves the offset.
+// A new request is sent when the object is read after seeking somewhere else
+type s3Object struct {
+	storage *S3
+	key     string
+	size    int64
+	offset  int64
+	// body is the response of the current range request, nil until the object is read
+	body io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+
+	if o.body == nil {
+		req, err := http.NewRequest("GET", o.storage.objectURL(o.key), nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", o.offset))
+
+		res, err := o.storage.send(req, http.StatusPartialContent)
+		if err != nil {
+			return 0, err
+		}
+
+		o.body = res.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("cannot seek before the start of the object")
+	}
+
+	if offset != o.offset {
+		o.Close()
+		o.offset = offset
+	}
+
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+
+	err := o.body.Close()
+	o.body = nil
+
+	return err
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data string) string {
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/YannHulot/petstore/api/models"
+)
+
+// ErrNotFound is returned when there is no object with the key
+var ErrNotFound = errors.New("object not found")
+
+// ErrInvalidKey is returned for the keys that were not generated by NewKey, e.g a key with ".."
+var ErrInvalidKey = errors.New("invalid object key")
+
+// keyPattern is the format of the keys: segments of lowercase letters and digits separated by slashes.
+// Nothing in a key can escape the directory or the bucket of the storage
+var keyPattern = regexp.MustCompile(`^[a-z0-9]+(/[a-z0-9]+)*$`)
+
+// Storage keeps the files uploaded by the clients, the objects are identified by the keys generated by NewKey
+type Storage interface {
+	// Put will save the content under the key, size is the number of bytes of the content
+	Put(key string, contentType string, content io.Reader, size int64) error
+	// Open will give access to the content of the object, ErrNotFound is returned when there is no object with the key
+	Open(key string) (Object, error)
+	// Delete will remove the object, deleting a missing object is not an error
+	Delete(key string) error
+}
+
+// Object is the content of a stored object, it can be read from any offset to answer the range requests.
+// It must be closed once read
+type Object interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// New will create the storage selected in the config
+func New(c models.Config) (Storage, error) {
+	switch c.StorageDriver {
+	case "", "local":
+		return NewLocal(c.StorageLocalDir)
+	case "s3":
+		return NewS3(c.S3Endpoint, c.S3Region, c.S3Bucket, c.S3AccessKeyID, c.S3SecretAccessKey)
+	default:
+		return nil, fmt.Errorf("storage driver %q is not supported", c.StorageDriver)
+	}
+}
+
+// NewKey will generate a random key under the prefix, e.g pets/1/9f86d081884c7d659a2feaa0c55ad015.
+// The names sent by the clients are never used in the keys
+func NewKey(prefix string) (string, error) {
+	random := make([]byte, 16)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+
+	key := prefix + "/" + hex.EncodeToString(random)
+	return key, validateKey(key)
+}
+
+// validateKey will make sure that the key has the format of the generated keys
+func validateKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return ErrInvalidKey
+	}
+
+	return nil
+}
@@ -4,13 +4,12 @@ import (
 	"log"
 
 	"github.com/YannHulot/petstore/api/models"
+	"github.com/YannHulot/petstore/api/notifications"
 	"github.com/YannHulot/petstore/api/server"
-	"github.com/jinzhu/gorm"
+	"github.com/YannHulot/petstore/api/storage"
 )
 
 func main() {
-	var db *gorm.DB
-
 	// Load the .env file containing the variables
 	err := models.LoadEnvFile()
 	if err != nil {
@@ -29,33 +28,38 @@ func main() {
 		log.Fatalf("error while validating the config: %s", err.Error())
 	}
 
-	// create the Database if possible
-	err = models.CreatePgDb(config)
+	// make sure the database exists, create the tables, run the migrations and open a connection to the DB.
+	// the database may still be starting so failed attempts are retried with a backoff
+	db, err := models.ConnectWithRetry(config)
 	if err != nil {
-		log.Printf("error while creating the database: %s", err.Error())
-		log.Printf("db may already exist")
-
-		// database may already exist , so we can try to connect to it
-		db, err = models.OpenAndTestDBConnection(config)
-		if err != nil {
-			// connection has failed for some reason
-			// needs investigation so we shut down the application
-			log.Fatalf("error while creating a connection with the db: %s", err.Error())
-		}
+		log.Fatalf("error while creating a connection with the db: %s", err.Error())
 	}
 
-	if db == nil {
-		// create the tables, run the migrations and open a connection to the DB
-		db, err = models.OpenAndTestDBConnection(config)
-		if err != nil {
-			log.Fatalf("error while creating a connection with the db: %s", err.Error())
-		}
+	defer db.Close()
+
+	// open the connections to the read replicas, if there are none the primary handles the reads
+	replicas, err := models.OpenReplicaPool(config)
+	if err != nil {
+		log.Fatalf("error while creating a connection with the replicas: %s", err.Error())
 	}
 
-	defer db.Close()
+	replicas.StartHealthChecks(config.DbReplicaHealthCheckInterval)
+	defer replicas.Close()
+
+	// create the notifier used to tell the adopters about the pets matching their saved searches
+	notifier, err := notifications.NewNotifier(config)
+	if err != nil {
+		log.Fatalf("error while creating the notifier: %s", err.Error())
+	}
+
+	// create the storage of the uploaded images, on the local disk or in an S3 compatible bucket
+	store, err := storage.New(config)
+	if err != nil {
+		log.Fatalf("error while creating the storage: %s", err.Error())
+	}
 
 	// create the router and the routes
-	router := server.CreateRouter(db)
+	router := server.CreateRouter(db, replicas, notifier, store, config)
 
 	// start the server
 	log.Fatal(router.Run(":8080"))